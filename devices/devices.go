@@ -1,61 +1,390 @@
 package devices
 
 import (
+	"context"
 	"fmt"
+	"net"
+	"net/http"
+	"net/url"
 	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/chyroc/go2tv/soapcalls"
 	"github.com/koron/go-ssdp"
 	"github.com/pkg/errors"
 )
 
-// LoadSSDPservices .
-func LoadSSDPservices(delay int) (map[string]string, error) {
-	// Reset device list every time we call this.
-	deviceList := make(map[string]string)
-	list, err := ssdp.Search(ssdp.All, delay, "")
+// Device - A media renderer found on the network.
+type Device struct {
+	UDN          string
+	FriendlyName string
+	Manufacturer string
+	ModelName    string
+	ModelNumber  string
+	// Location - URL of the device description,
+	// the one to pass around as the DMR url.
+	Location string
+	Icons    []Icon
+	// Services - The service types the device supports.
+	Services []string
+	// Interface - The local interface the device was found on.
+	// Serve the media on its address for the device to reach it.
+	Interface Interface
+	// MAC - The hardware address of the device, as found in the
+	// ARP table. Needed to wake it up, empty if unknown.
+	MAC string
+}
+
+// Icon - A device icon. The URL is absolute.
+type Icon struct {
+	MIMEType string
+	Width    int
+	Height   int
+	Depth    int
+	URL      string
+}
+
+const (
+	// descriptionWorkers - How many device descriptions
+	// are fetched at the same time during discovery.
+	descriptionWorkers = 8
+	// descriptionTimeout - Per device deadline
+	// for fetching its description.
+	descriptionTimeout = 5 * time.Second
+)
+
+var (
+	// ErrUnreachable - The device answered the search, but
+	// its description could not be fetched.
+	ErrUnreachable = errors.New("device unreachable")
+	// ErrBadDescription - The device description could not be parsed.
+	ErrBadDescription = errors.New("bad device description")
+)
+
+// DeviceError - A device that answered the search
+// but could not be added to the device list.
+type DeviceError struct {
+	Location string
+	Err      error
+}
+
+func (e *DeviceError) Error() string {
+	return e.Location + ": " + e.Err.Error()
+}
+
+func (e *DeviceError) Unwrap() error {
+	return e.Err
+}
+
+// SearchResult - The outcome of a device search.
+type SearchResult struct {
+	// Devices - Sorted by friendly name and deduplicated by UDN.
+	Devices []Device
+	// Failures - The devices whose descriptions failed to load.
+	Failures []*DeviceError
+}
+
+// LoadSSDPservices - Search for the media renderers on the network.
+// The devices are sorted by friendly name and deduplicated by UDN.
+func LoadSSDPservices(delay int) ([]Device, error) {
+	return LoadSSDPservicesContext(context.Background(), nil, delay)
+}
+
+// LoadSSDPservicesContext - Same as LoadSSDPservices, but the device
+// description requests are bound to the ctx. If client is nil, a
+// client with a 10 seconds timeout is used.
+func LoadSSDPservicesContext(ctx context.Context, client *http.Client, delay int) ([]Device, error) {
+	res, err := SearchDevices(ctx, client, delay)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(res.Devices) == 0 {
+		if len(res.Failures) > 0 {
+			return nil, fmt.Errorf("loadSSDPservices: No available Media Renderers, %d failed to load: %w", len(res.Failures), res.Failures[0])
+		}
+		return nil, errors.New("loadSSDPservices: No available Media Renderers")
+	}
+
+	rememberDevices(res.Devices)
+
+	return res.Devices, nil
+}
+
+// rememberDevices - Record the devices in the default store, so
+// that they can be cast to by name later on. This is best effort,
+// not being able to write the store doesn't fail the discovery.
+func rememberDevices(devs []Device) {
+	store, err := OpenStore("")
 	if err != nil {
-		return nil, fmt.Errorf("LoadSSDPservices search error: %w", err)
+		return
+	}
+
+	store.Remember(devs...)
+	store.Save()
+}
+
+// SearchDevices - Search for the media renderers on all the
+// interfaces returned by ListInterfaces. The device descriptions
+// are fetched concurrently, each one with its own deadline, and
+// the devices that failed to load are reported alongside the
+// ones that didn't.
+func SearchDevices(ctx context.Context, client *http.Client, delay int) (*SearchResult, error) {
+	ifaces, err := ListInterfaces()
+	if err != nil || len(ifaces) == 0 {
+		// Let the OS pick.
+		ifaces = []Interface{{}}
+	}
+
+	return SearchDevicesOn(ctx, client, delay, ifaces)
+}
+
+// SearchDevicesOn - Same as SearchDevices, but only searches
+// on the given interfaces. Each device records the interface
+// it was found on.
+func SearchDevicesOn(ctx context.Context, client *http.Client, delay int, ifaces []Interface) (*SearchResult, error) {
+	type searchResult struct {
+		iface Interface
+		list  []ssdp.Service
+		err   error
+	}
+
+	results := make(chan searchResult, len(ifaces))
+	for _, iface := range ifaces {
+		go func(iface Interface) {
+			localAddr := ""
+			if iface.IP != "" {
+				localAddr = net.JoinHostPort(iface.IP, "0")
+			}
+			list, err := ssdp.Search(ssdp.All, delay, localAddr)
+			results <- searchResult{iface, list, err}
+		}(iface)
 	}
 
-	for _, srv := range list {
-		// We only care about the AVTransport services for basic actions
-		// (stop,play,pause). If we need support other functionalities
-		// like volume control we need to use the RenderingControl service.
-		if srv.Type == "urn:schemas-upnp-org:service:AVTransport:1" {
-			friendlyName, err := soapcalls.GetFriendlyName(srv.Location)
-			if err != nil {
+	hits := make([]searchHit, 0)
+	seen := make(map[string]bool)
+	var searchErr error
+	searched := 0
+	for range ifaces {
+		r := <-results
+		if r.err != nil {
+			searchErr = r.err
+			continue
+		}
+		searched++
+
+		for _, srv := range r.list {
+			// We only care about the AVTransport services for basic actions
+			// (stop,play,pause). If we need support other functionalities
+			// like volume control we need to use the RenderingControl service.
+			if srv.Type != avTransportType {
+				continue
+			}
+
+			// Devices answering on more than one
+			// interface show up more than once.
+			udn := udnFromUSN(srv.USN)
+			if (udn != "" && seen[udn]) || seen[srv.Location] {
 				continue
 			}
+			seen[udn], seen[srv.Location] = true, true
 
-			deviceList[friendlyName] = srv.Location
+			hits = append(hits, searchHit{location: srv.Location, iface: r.iface})
 		}
 	}
 
-	if len(deviceList) > 0 {
-		return deviceList, nil
+	if searched == 0 {
+		return nil, fmt.Errorf("SearchDevices search error: %w", searchErr)
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("SearchDevices context error: %w", err)
 	}
 
-	return nil, errors.New("loadSSDPservices: No available Media Renderers")
+	return loadDevices(ctx, client, hits), nil
+}
+
+// searchHit - A search reply and the interface it arrived on.
+type searchHit struct {
+	location string
+	iface    Interface
 }
 
-// DevicePicker .
-func DevicePicker(devices map[string]string, i int) (string, error) {
-	if i > len(devices) || len(devices) == 0 || i <= 0 {
-		return "", errors.New("devicePicker: Requested device not available")
+// loadDevices - Fetch the device descriptions
+// with a bounded pool of workers.
+func loadDevices(ctx context.Context, client *http.Client, hits []searchHit) *SearchResult {
+	type result struct {
+		hit searchHit
+		dev *Device
+		err error
 	}
 
-	keys := make([]string, 0)
-	for k := range devices {
-		keys = append(keys, k)
+	jobs := make(chan searchHit)
+	results := make(chan result)
+
+	workers := descriptionWorkers
+	if len(hits) < workers {
+		workers = len(hits)
 	}
 
-	sort.Strings(keys)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for hit := range jobs {
+				dev, err := loadDevice(ctx, client, hit.location)
+				results <- result{hit, dev, err}
+			}
+		}()
+	}
 
-	for q, k := range keys {
-		if i == q+1 {
-			return devices[k], nil
+	go func() {
+		for _, hit := range hits {
+			jobs <- hit
 		}
+		close(jobs)
+		wg.Wait()
+		close(results)
+	}()
+
+	res := &SearchResult{
+		Devices: make([]Device, 0),
+	}
+	seen := make(map[string]bool)
+	for r := range results {
+		if r.err != nil {
+			res.Failures = append(res.Failures, &DeviceError{Location: r.hit.location, Err: r.err})
+			continue
+		}
+
+		if r.hit.iface.IP != "" {
+			r.dev.Interface = r.hit.iface
+		}
+
+		if seen[r.dev.UDN] {
+			continue
+		}
+		seen[r.dev.UDN] = true
+
+		res.Devices = append(res.Devices, *r.dev)
+	}
+
+	sortDevices(res.Devices)
+	sort.Slice(res.Failures, func(i, j int) bool {
+		return res.Failures[i].Location < res.Failures[j].Location
+	})
+
+	return res
+}
+
+// DevicePicker - Select a device by its 1-based index in the
+// list, its UDN or its friendly name. Friendly names that are
+// shared by more than one device can't be used.
+func DevicePicker(devices []Device, selector string) (*Device, error) {
+	if len(devices) == 0 {
+		return nil, errors.New("devicePicker: Requested device not available")
+	}
+
+	if i, err := strconv.Atoi(selector); err == nil {
+		if i <= 0 || i > len(devices) {
+			return nil, errors.New("devicePicker: Requested device not available")
+		}
+		return &devices[i-1], nil
+	}
+
+	udn := strings.TrimPrefix(selector, "uuid:")
+	for q := range devices {
+		if strings.TrimPrefix(devices[q].UDN, "uuid:") == udn {
+			return &devices[q], nil
+		}
+	}
+
+	var found *Device
+	for q := range devices {
+		if !strings.EqualFold(devices[q].FriendlyName, selector) {
+			continue
+		}
+		if found != nil {
+			return nil, fmt.Errorf("devicePicker: More than one device named %q, use the index or the UDN", selector)
+		}
+		found = &devices[q]
+	}
+
+	if found == nil {
+		return nil, errors.New("devicePicker: Requested device not available")
 	}
-	return "", errors.New("devicePicker: Something went terribly wrong")
+
+	return found, nil
+}
+
+func loadDevice(ctx context.Context, client *http.Client, location string) (*Device, error) {
+	// One slow device should not hold back the rest.
+	ctx, cancel := context.WithTimeout(ctx, descriptionTimeout)
+	defer cancel()
+
+	root, err := soapcalls.GetDeviceDescriptionContext(ctx, client, location)
+	if err != nil {
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			return nil, fmt.Errorf("%w: %v", ErrUnreachable, err)
+		}
+		return nil, fmt.Errorf("%w: %v", ErrBadDescription, err)
+	}
+
+	// The MediaRenderer may be an embedded device.
+	d := root.MediaRenderer()
+	if d == nil {
+		d = &root.Device
+	}
+
+	dev := &Device{
+		UDN:          d.UDN,
+		FriendlyName: d.FriendlyName,
+		Manufacturer: d.Manufacturer,
+		ModelName:    d.ModelName,
+		ModelNumber:  d.ModelNumber,
+		Location:     location,
+		Interface:    routeInterface(location),
+		MAC:          lookupMAC(ctx, location),
+	}
+
+	// Devices without a UDN are told apart by location.
+	if dev.UDN == "" {
+		dev.UDN = location
+	}
+
+	for _, icon := range d.IconList {
+		iconURL, err := root.ResolveURL(location, icon.URL)
+		if err != nil {
+			continue
+		}
+
+		dev.Icons = append(dev.Icons, Icon{
+			MIMEType: icon.MIMEType,
+			Width:    icon.Width,
+			Height:   icon.Height,
+			Depth:    icon.Depth,
+			URL:      iconURL,
+		})
+	}
+
+	for _, service := range d.ServiceList.Services {
+		dev.Services = append(dev.Services, service.Type)
+	}
+
+	return dev, nil
+}
+
+// udnFromUSN - The USN of a service advertisement
+// is "<UDN>::<service type>".
+func udnFromUSN(usn string) string {
+	if i := strings.Index(usn, "::"); i > 0 {
+		return usn[:i]
+	}
+
+	return ""
 }
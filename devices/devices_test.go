@@ -0,0 +1,152 @@
+package devices
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// rendererDescription - A minimal MediaRenderer description.
+func rendererDescription(udn, name string) string {
+	return `<root><device><deviceType>urn:schemas-upnp-org:device:MediaRenderer:1</deviceType>` +
+		`<UDN>` + udn + `</UDN><friendlyName>` + name + `</friendlyName>` +
+		`<iconList><icon><mimetype>image/png</mimetype><width>48</width><height>48</height><depth>24</depth><url>icon.png</url></icon></iconList>` +
+		`<serviceList><service><serviceType>urn:schemas-upnp-org:service:AVTransport:1</serviceType>` +
+		`<serviceId>urn:upnp-org:serviceId:AVTransport</serviceId><controlURL>/avt</controlURL></service></serviceList>` +
+		`</device></root>`
+}
+
+// newDescriptionServer - Serve the descriptions by path.
+func newDescriptionServer(descriptions map[string]string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		d, ok := descriptions[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		io.WriteString(w, d)
+	}))
+}
+
+func TestDevicePicker(t *testing.T) {
+	devs := []Device{
+		{UDN: "uuid:aaaa", FriendlyName: "Living Room"},
+		{UDN: "uuid:bbbb", FriendlyName: "Bedroom"},
+		{UDN: "uuid:cccc", FriendlyName: "Bedroom"},
+	}
+
+	tt := []struct {
+		name     string
+		selector string
+		wantUDN  string
+		wantErr  bool
+	}{
+		{`Index`, "2", "uuid:bbbb", false},
+		{`Index out of range`, "4", "", true},
+		{`Index zero`, "0", "", true},
+		{`UDN`, "uuid:cccc", "uuid:cccc", false},
+		{`UDN without prefix`, "aaaa", "uuid:aaaa", false},
+		{`Name, any case`, "living room", "uuid:aaaa", false},
+		{`Duplicate name`, "Bedroom", "", true},
+		{`Unknown name`, "Kitchen", "", true},
+	}
+
+	for _, tc := range tt {
+		dev, err := DevicePicker(devs, tc.selector)
+		if (err != nil) != tc.wantErr {
+			t.Errorf("%s: got error: %v, want error: %t", tc.name, err, tc.wantErr)
+			continue
+		}
+
+		if err == nil && dev.UDN != tc.wantUDN {
+			t.Errorf("%s: got: %s, want: %s.", tc.name, dev.UDN, tc.wantUDN)
+		}
+	}
+
+	if _, err := DevicePicker(nil, "1"); err == nil {
+		t.Errorf("DevicePicker: expected error for an empty list")
+	}
+}
+
+func TestUDNfromUSN(t *testing.T) {
+	tt := []struct {
+		input string
+		want  string
+	}{
+		{"uuid:1111::urn:schemas-upnp-org:service:AVTransport:1", "uuid:1111"},
+		{"uuid:1111", ""},
+		{"::urn:schemas-upnp-org:service:AVTransport:1", ""},
+	}
+
+	for _, tc := range tt {
+		if out := udnFromUSN(tc.input); out != tc.want {
+			t.Errorf("%s: got: %s, want: %s.", tc.input, out, tc.want)
+		}
+	}
+}
+
+func TestLoadDevice(t *testing.T) {
+	srv := newDescriptionServer(map[string]string{
+		"/dmr/description.xml": rendererDescription("uuid:1111", "Living Room"),
+	})
+	defer srv.Close()
+
+	location := srv.URL + "/dmr/description.xml"
+	dev, err := loadDevice(context.Background(), nil, location)
+	if err != nil {
+		t.Fatalf("Failed to call loadDevice due to %s", err.Error())
+	}
+
+	if dev.UDN != "uuid:1111" || dev.FriendlyName != "Living Room" || dev.Location != location {
+		t.Errorf("loadDevice: got: %+v", dev)
+	}
+
+	if len(dev.Icons) != 1 || dev.Icons[0].URL != srv.URL+"/dmr/icon.png" {
+		t.Errorf("loadDevice: got icons: %+v, want: %s", dev.Icons, srv.URL+"/dmr/icon.png")
+	}
+
+	if len(dev.Services) != 1 || dev.Services[0] != avTransportType {
+		t.Errorf("loadDevice: got services: %v, want: %s", dev.Services, avTransportType)
+	}
+}
+
+func TestLoadDevices(t *testing.T) {
+	srv := newDescriptionServer(map[string]string{
+		"/a.xml":   rendererDescription("uuid:2222", "Bedroom"),
+		"/b.xml":   rendererDescription("uuid:1111", "Living Room"),
+		"/dup.xml": rendererDescription("uuid:1111", "Living Room"),
+		"/bad.xml": "not xml",
+	})
+	defer srv.Close()
+
+	hits := []searchHit{
+		{location: srv.URL + "/a.xml"},
+		{location: srv.URL + "/b.xml"},
+		{location: srv.URL + "/dup.xml"},
+		{location: srv.URL + "/bad.xml"},
+		{location: "http://127.0.0.1:1/unreachable.xml"},
+	}
+
+	res := loadDevices(context.Background(), nil, hits)
+
+	if len(res.Devices) != 2 || res.Devices[0].FriendlyName != "Bedroom" || res.Devices[1].FriendlyName != "Living Room" {
+		t.Errorf("loadDevices: got: %+v, want: Bedroom and Living Room", res.Devices)
+	}
+
+	if len(res.Failures) != 2 {
+		t.Fatalf("loadDevices: got: %d failures, want: 2", len(res.Failures))
+	}
+
+	for _, f := range res.Failures {
+		want := ErrBadDescription
+		if f.Location == "http://127.0.0.1:1/unreachable.xml" {
+			want = ErrUnreachable
+		}
+		if !errors.Is(f, want) {
+			t.Errorf("loadDevices: %s: got: %v, want: %v", f.Location, f.Err, want)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package devices
+
+import (
+	"fmt"
+	"net"
+)
+
+// Interface - A local network interface address
+// discovery can run on and media can be served from.
+type Interface struct {
+	Name string
+	IP   string
+}
+
+// ListInterfaces - Return the IPv4 addresses of the interfaces
+// that are up and multicast capable, loopback excluded.
+func ListInterfaces() ([]Interface, error) {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil, fmt.Errorf("ListInterfaces error: %w", err)
+	}
+
+	list := make([]Interface, 0)
+	for _, ifi := range ifaces {
+		if ifi.Flags&net.FlagUp == 0 || ifi.Flags&net.FlagLoopback != 0 || ifi.Flags&net.FlagMulticast == 0 {
+			continue
+		}
+
+		addrs, err := ifi.Addrs()
+		if err != nil {
+			continue
+		}
+
+		for _, a := range addrs {
+			ip, _, err := net.ParseCIDR(a.String())
+			if err != nil || ip.To4() == nil || ip.IsUnspecified() {
+				continue
+			}
+
+			list = append(list, Interface{Name: ifi.Name, IP: ip.String()})
+		}
+	}
+
+	return list, nil
+}
+
+// routeInterface - Return the local interface the OS
+// would use to reach the host of the location.
+func routeInterface(location string) Interface {
+	conn, err := net.Dial("udp", locationHostPort(location))
+	if err != nil {
+		return Interface{}
+	}
+	defer conn.Close()
+
+	local, ok := conn.LocalAddr().(*net.UDPAddr)
+	if !ok {
+		return Interface{}
+	}
+
+	iface := Interface{IP: local.IP.String()}
+	list, _ := ListInterfaces()
+	for _, i := range list {
+		if i.IP == iface.IP {
+			iface.Name = i.Name
+			break
+		}
+	}
+
+	return iface
+}
@@ -0,0 +1,228 @@
+package devices
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chyroc/go2tv/utils"
+	"github.com/pkg/errors"
+)
+
+// unicastSearchTimeout - How long to wait for
+// the reply to the unicast M-SEARCH.
+const unicastSearchTimeout = 2 * time.Second
+
+var (
+	// commonPorts - The ports media renderers
+	// commonly serve their descriptions on.
+	commonPorts = []int{49152, 49153, 49154, 49494, 9197, 7676, 1400, 8200, 8080, 8008, 80, 52235, 55000, 1900}
+
+	// commonPaths - The paths media renderers
+	// commonly serve their descriptions on.
+	commonPaths = []string{
+		"/description.xml",
+		"/rootDesc.xml",
+		"/dmr",
+		"/dmr/description.xml",
+		"/xml/device_description.xml",
+		"/DeviceDescription.xml",
+		"/upnp/desc.xml",
+		"/dd.xml",
+		"/MediaRenderer/desc.xml",
+		"/ssdp/device-desc.xml",
+	}
+)
+
+// ProbeDevice - Locate a media renderer without relying on multicast
+// SSDP. A unicast M-SEARCH is sent to the host first and, if there is
+// no reply, the common description paths are tried on the port range.
+// The host may include a port, in which case only that port is probed.
+// If fromPort and toPort are 0, the ports media renderers commonly use
+// are probed instead.
+func ProbeDevice(ctx context.Context, client *http.Client, host string, fromPort, toPort int) (*Device, error) {
+	ports, err := probePorts(host, fromPort, toPort)
+	if err != nil {
+		return nil, fmt.Errorf("ProbeDevice error: %w", err)
+	}
+
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+
+	if location, err := unicastSearch(ctx, host); err == nil {
+		if dev, err := loadRenderer(ctx, client, location); err == nil {
+			return dev, nil
+		}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	jobs := make(chan int)
+	found := make(chan *Device, 1)
+
+	workers := descriptionWorkers
+	if len(ports) < workers {
+		workers = len(ports)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for port := range jobs {
+				dev, err := probePort(ctx, client, host, port)
+				if err != nil {
+					continue
+				}
+
+				select {
+				case found <- dev:
+					cancel()
+				default:
+				}
+			}
+		}()
+	}
+
+	go func() {
+		defer close(jobs)
+		for _, port := range ports {
+			select {
+			case jobs <- port:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	wg.Wait()
+
+	select {
+	case dev := <-found:
+		return dev, nil
+	default:
+	}
+
+	return nil, fmt.Errorf("ProbeDevice: no Media Renderer found on %s", host)
+}
+
+func probePorts(host string, fromPort, toPort int) ([]int, error) {
+	if _, p, err := net.SplitHostPort(host); err == nil {
+		port, err := strconv.Atoi(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid port: %w", err)
+		}
+		return []int{port}, nil
+	}
+
+	if fromPort == 0 && toPort == 0 {
+		return commonPorts, nil
+	}
+
+	if fromPort <= 0 || toPort > 65535 || fromPort > toPort {
+		return nil, errors.New("invalid port range")
+	}
+
+	ports := make([]int, 0, toPort-fromPort+1)
+	for p := fromPort; p <= toPort; p++ {
+		ports = append(ports, p)
+	}
+
+	return ports, nil
+}
+
+func probePort(ctx context.Context, client *http.Client, host string, port int) (*Device, error) {
+	hostPort := net.JoinHostPort(host, strconv.Itoa(port))
+	if !utils.HostPortIsAlive(hostPort) {
+		return nil, ErrUnreachable
+	}
+
+	for _, path := range commonPaths {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		dev, err := loadRenderer(ctx, client, "http://"+hostPort+path)
+		if err == nil {
+			return dev, nil
+		}
+	}
+
+	return nil, ErrBadDescription
+}
+
+// loadRenderer - Same as loadDevice, but only
+// accepts devices with an AVTransport service.
+func loadRenderer(ctx context.Context, client *http.Client, location string) (*Device, error) {
+	dev, err := loadDevice(ctx, client, location)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, s := range dev.Services {
+		if strings.HasPrefix(s, "urn:schemas-upnp-org:service:AVTransport:") {
+			return dev, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w: no AVTransport service", ErrBadDescription)
+}
+
+// unicastSearch - Send an M-SEARCH straight to the host
+// and return the LOCATION of the first reply.
+func unicastSearch(ctx context.Context, host string) (string, error) {
+	raddr, err := net.ResolveUDPAddr("udp4", net.JoinHostPort(host, "1900"))
+	if err != nil {
+		return "", fmt.Errorf("unicastSearch resolve error: %w", err)
+	}
+
+	conn, err := net.ListenUDP("udp4", nil)
+	if err != nil {
+		return "", fmt.Errorf("unicastSearch listen error: %w", err)
+	}
+	defer conn.Close()
+
+	deadline := time.Now().Add(unicastSearchTimeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	conn.SetDeadline(deadline)
+
+	msg := "M-SEARCH * HTTP/1.1\r\n" +
+		"HOST: " + raddr.String() + "\r\n" +
+		"MAN: \"ssdp:discover\"\r\n" +
+		"ST: " + avTransportType + "\r\n" +
+		"USER-AGENT: Go2TV\r\n\r\n"
+
+	if _, err := conn.WriteTo([]byte(msg), raddr); err != nil {
+		return "", fmt.Errorf("unicastSearch write error: %w", err)
+	}
+
+	buf := make([]byte, 65535)
+	for {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			return "", fmt.Errorf("unicastSearch read error: %w", err)
+		}
+
+		resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(buf[:n])), nil)
+		if err != nil {
+			continue
+		}
+		resp.Body.Close()
+
+		if location := resp.Header.Get("LOCATION"); location != "" {
+			return location, nil
+		}
+	}
+}
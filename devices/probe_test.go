@@ -0,0 +1,65 @@
+package devices
+
+import (
+	"context"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestProbePorts(t *testing.T) {
+	tt := []struct {
+		name     string
+		host     string
+		from, to int
+		want     []int
+		wantErr  bool
+	}{
+		{`Host with port`, "192.168.1.50:8080", 0, 0, []int{8080}, false},
+		{`Range`, "192.168.1.50", 8000, 8002, []int{8000, 8001, 8002}, false},
+		{`Common ports`, "192.168.1.50", 0, 0, commonPorts, false},
+		{`Reversed range`, "192.168.1.50", 9000, 8000, nil, true},
+		{`Out of range`, "192.168.1.50", 1, 70000, nil, true},
+	}
+
+	for _, tc := range tt {
+		out, err := probePorts(tc.host, tc.from, tc.to)
+		if (err != nil) != tc.wantErr {
+			t.Errorf("%s: got error: %v, want error: %t", tc.name, err, tc.wantErr)
+			continue
+		}
+
+		if !reflect.DeepEqual(out, tc.want) {
+			t.Errorf("%s: got: %v, want: %v.", tc.name, out, tc.want)
+		}
+	}
+}
+
+func TestProbeDevice(t *testing.T) {
+	srv := newDescriptionServer(map[string]string{
+		"/dmr": rendererDescription("uuid:1111", "Living Room"),
+	})
+	defer srv.Close()
+
+	u, _ := url.Parse(srv.URL)
+
+	dev, err := ProbeDevice(context.Background(), nil, u.Host, 0, 0)
+	if err != nil {
+		t.Fatalf("Failed to call ProbeDevice due to %s", err.Error())
+	}
+
+	if dev.UDN != "uuid:1111" || dev.Location != srv.URL+"/dmr" {
+		t.Errorf("ProbeDevice: got: %s at %s, want: uuid:1111 at %s", dev.UDN, dev.Location, srv.URL+"/dmr")
+	}
+
+	// Not a media renderer.
+	other := newDescriptionServer(map[string]string{
+		"/description.xml": `<root><device><UDN>uuid:2222</UDN></device></root>`,
+	})
+	defer other.Close()
+
+	u, _ = url.Parse(other.URL)
+	if _, err := ProbeDevice(context.Background(), nil, u.Host, 0, 0); err == nil {
+		t.Errorf("ProbeDevice: expected error for a device without AVTransport")
+	}
+}
@@ -0,0 +1,375 @@
+package devices
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/chyroc/go2tv/utils"
+	"github.com/koron/go-ssdp"
+	"github.com/pkg/errors"
+)
+
+const (
+	avTransportType = "urn:schemas-upnp-org:service:AVTransport:1"
+
+	// defaultMaxAge is used for the advertisements
+	// without a valid CACHE-CONTROL max-age.
+	defaultMaxAge = 1800
+
+	defaultHealthCheckInterval = 30 * time.Second
+)
+
+// ChangeType - The kind of a registry change.
+type ChangeType int
+
+const (
+	// DeviceAdded - A media renderer showed up.
+	DeviceAdded ChangeType = iota
+	// DeviceRemoved - A media renderer said goodbye, its
+	// advertisement expired or it failed a health check.
+	DeviceRemoved
+)
+
+// DeviceChange - A change in the list of media renderers.
+type DeviceChange struct {
+	Type   ChangeType
+	Device Device
+}
+
+// Registry - Keeps the list of media renderers on the network
+// up to date in the background. It listens for the SSDP NOTIFY
+// ssdp:alive and ssdp:byebye messages, drops the entries once
+// their max-age expires and periodically health checks the rest.
+type Registry struct {
+	// Client - Used for the device description requests.
+	// A client with a 10 seconds timeout is used if nil.
+	Client *http.Client
+	// HealthCheckInterval - How often the entries are
+	// health checked. Defaults to 30 seconds.
+	HealthCheckInterval time.Duration
+	// SearchDelay - How long to wait for the replies to the
+	// initial M-SEARCH, in seconds. Defaults to 1 second.
+	SearchDelay int
+
+	entries map[string]*registryEntry
+	pending map[string]bool
+	changes chan DeviceChange
+	monitor *ssdp.Monitor
+	cancel  context.CancelFunc
+	ctx     context.Context
+	closed  bool
+	wg      sync.WaitGroup
+	mu      sync.RWMutex
+}
+
+type registryEntry struct {
+	device  Device
+	expires time.Time
+}
+
+// NewRegistry - Create a new discovery registry. Call
+// Start to begin tracking the media renderers.
+func NewRegistry() *Registry {
+	return &Registry{
+		entries: make(map[string]*registryEntry),
+		pending: make(map[string]bool),
+		changes: make(chan DeviceChange, 32),
+	}
+}
+
+// Start - Start listening for the SSDP messages and search for
+// the media renderers that are already on the network. The
+// registry stops when the ctx is done or Close is called.
+func (r *Registry) Start(ctx context.Context) error {
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+		return errors.New("registry: closed")
+	}
+	if r.monitor != nil {
+		r.mu.Unlock()
+		return errors.New("registry: already started")
+	}
+
+	r.ctx, r.cancel = context.WithCancel(ctx)
+	r.monitor = &ssdp.Monitor{
+		Alive: r.handleAlive,
+		Bye:   r.handleBye,
+	}
+	r.mu.Unlock()
+
+	if err := r.monitor.Start(); err != nil {
+		r.mu.Lock()
+		r.cancel()
+		r.monitor = nil
+		r.mu.Unlock()
+		return fmt.Errorf("registry: monitor start error: %w", err)
+	}
+
+	interval := r.HealthCheckInterval
+	if interval <= 0 {
+		interval = defaultHealthCheckInterval
+	}
+
+	delay := r.SearchDelay
+	if delay <= 0 {
+		delay = 1
+	}
+
+	r.wg.Add(2)
+	go func() {
+		defer r.wg.Done()
+		r.search(delay)
+	}()
+	go func() {
+		defer r.wg.Done()
+		r.healthCheckLoop(interval)
+	}()
+
+	go func() {
+		<-r.ctx.Done()
+		r.Close()
+	}()
+
+	return nil
+}
+
+// Devices - Return the media renderers currently on the
+// network, sorted by friendly name.
+func (r *Registry) Devices() []Device {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	list := make([]Device, 0, len(r.entries))
+	for _, e := range r.entries {
+		list = append(list, e.device)
+	}
+
+	sortDevices(list)
+
+	return list
+}
+
+// Changes - Return the channel the registry changes are sent to.
+// The channel is buffered and changes are dropped while it's
+// full, Devices always returns the current list. The channel
+// is closed once the registry stops.
+func (r *Registry) Changes() <-chan DeviceChange {
+	return r.changes
+}
+
+// Close - Stop the registry.
+func (r *Registry) Close() error {
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+		return nil
+	}
+	r.closed = true
+	if r.cancel != nil {
+		r.cancel()
+	}
+	monitor := r.monitor
+	r.mu.Unlock()
+
+	if monitor != nil {
+		monitor.Close()
+	}
+
+	r.wg.Wait()
+
+	r.mu.Lock()
+	close(r.changes)
+	r.mu.Unlock()
+
+	return nil
+}
+
+func (r *Registry) search(delay int) {
+	list, err := ssdp.Search(avTransportType, delay, "")
+	if err != nil {
+		return
+	}
+
+	for _, srv := range list {
+		r.alive(srv.Type, srv.USN, srv.Location, srv.MaxAge())
+	}
+}
+
+func (r *Registry) handleAlive(m *ssdp.AliveMessage) {
+	r.alive(m.Type, m.USN, m.Location, m.MaxAge())
+}
+
+func (r *Registry) handleBye(m *ssdp.ByeMessage) {
+	udn := udnFromUSN(m.USN)
+	if udn == "" {
+		udn = m.USN
+	}
+
+	r.remove(udn)
+}
+
+func (r *Registry) alive(nt, usn, location string, maxAge int) {
+	// Same as in LoadSSDPservices, we only
+	// care about the AVTransport services.
+	if nt != avTransportType {
+		return
+	}
+
+	if maxAge <= 0 {
+		maxAge = defaultMaxAge
+	}
+	expires := time.Now().Add(time.Duration(maxAge) * time.Second)
+
+	udn := udnFromUSN(usn)
+
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+		return
+	}
+
+	// A known device that is still at the same
+	// location only needs its expiry refreshed.
+	if e, ok := r.entries[udn]; ok && e.device.Location == location {
+		e.expires = expires
+		r.mu.Unlock()
+		return
+	}
+
+	// The description is loaded in the background, so that
+	// a slow device doesn't hold back the other advertisements.
+	// Devices advertise repeatedly, one load per USN is enough.
+	if r.pending[usn] {
+		r.mu.Unlock()
+		return
+	}
+	r.pending[usn] = true
+	ctx := r.ctx
+	r.wg.Add(1)
+	r.mu.Unlock()
+
+	go func() {
+		defer r.wg.Done()
+
+		dev, err := loadDevice(ctx, r.Client, location)
+
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		delete(r.pending, usn)
+
+		if err != nil || r.closed {
+			return
+		}
+
+		old, known := r.entries[dev.UDN]
+		r.entries[dev.UDN] = &registryEntry{
+			device:  *dev,
+			expires: expires,
+		}
+
+		if known {
+			// Already loaded through another USN.
+			if old.device.Location == dev.Location {
+				return
+			}
+			// The device moved, e.g. it got a new IP address.
+			r.notify(DeviceChange{Type: DeviceRemoved, Device: old.device})
+		}
+		r.notify(DeviceChange{Type: DeviceAdded, Device: *dev})
+	}()
+}
+
+func (r *Registry) remove(udn string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	e, ok := r.entries[udn]
+	if !ok || r.closed {
+		return
+	}
+
+	delete(r.entries, udn)
+	r.notify(DeviceChange{Type: DeviceRemoved, Device: e.device})
+}
+
+func (r *Registry) healthCheckLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.ctx.Done():
+			return
+		case <-ticker.C:
+			r.healthCheck()
+		}
+	}
+}
+
+func (r *Registry) healthCheck() {
+	now := time.Now()
+
+	r.mu.RLock()
+	toCheck := make(map[string]string, len(r.entries))
+	expired := make([]string, 0)
+	for udn, e := range r.entries {
+		if now.After(e.expires) {
+			expired = append(expired, udn)
+			continue
+		}
+		toCheck[udn] = e.device.Location
+	}
+	r.mu.RUnlock()
+
+	for _, udn := range expired {
+		r.remove(udn)
+	}
+
+	for udn, location := range toCheck {
+		if !utils.HostPortIsAlive(locationHostPort(location)) {
+			r.remove(udn)
+		}
+	}
+}
+
+// notify - Send the change without blocking.
+// Must be called with the lock held.
+func (r *Registry) notify(c DeviceChange) {
+	select {
+	case r.changes <- c:
+	default:
+	}
+}
+
+func locationHostPort(location string) string {
+	u, err := url.Parse(location)
+	if err != nil {
+		return ""
+	}
+
+	if u.Port() != "" {
+		return u.Host
+	}
+
+	if u.Scheme == "https" {
+		return net.JoinHostPort(u.Hostname(), "443")
+	}
+
+	return net.JoinHostPort(u.Hostname(), "80")
+}
+
+func sortDevices(list []Device) {
+	sort.SliceStable(list, func(i, j int) bool {
+		if list[i].FriendlyName != list[j].FriendlyName {
+			return list[i].FriendlyName < list[j].FriendlyName
+		}
+		return list[i].UDN < list[j].UDN
+	})
+}
@@ -0,0 +1,98 @@
+package devices
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/koron/go-ssdp"
+)
+
+func waitChange(t *testing.T, r *Registry) DeviceChange {
+	t.Helper()
+
+	select {
+	case c := <-r.Changes():
+		return c
+	case <-time.After(5 * time.Second):
+		t.Fatalf("no registry change")
+	}
+
+	return DeviceChange{}
+}
+
+func TestRegistry(t *testing.T) {
+	var loads int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&loads, 1)
+		// Slow enough for the repeated advertisements
+		// to arrive while the description is loading.
+		time.Sleep(100 * time.Millisecond)
+		io.WriteString(w, rendererDescription("uuid:1111", "Living Room"))
+	}))
+	defer srv.Close()
+
+	r := NewRegistry()
+	r.ctx, r.cancel = context.WithCancel(context.Background())
+	defer r.Close()
+
+	usn := "uuid:1111::" + avTransportType
+	location := srv.URL + "/dmr"
+
+	r.alive("urn:schemas-upnp-org:service:ConnectionManager:1", "uuid:1111::cm", location, 1800)
+	for i := 0; i < 3; i++ {
+		r.alive(avTransportType, usn, location, 1800)
+	}
+
+	if c := waitChange(t, r); c.Type != DeviceAdded || c.Device.UDN != "uuid:1111" {
+		t.Errorf("alive: got: %+v, want: uuid:1111 added", c)
+	}
+
+	if n := atomic.LoadInt32(&loads); n != 1 {
+		t.Errorf("alive: got: %d description loads, want: 1", n)
+	}
+
+	if devs := r.Devices(); len(devs) != 1 {
+		t.Errorf("Devices: got: %d devices, want: 1", len(devs))
+	}
+
+	r.handleBye(&ssdp.ByeMessage{Type: avTransportType, USN: usn})
+
+	if c := waitChange(t, r); c.Type != DeviceRemoved || c.Device.UDN != "uuid:1111" {
+		t.Errorf("byebye: got: %+v, want: uuid:1111 removed", c)
+	}
+
+	// Back, with an advertisement that expired already.
+	r.alive(avTransportType, usn, location, 1800)
+	waitChange(t, r)
+
+	r.mu.Lock()
+	r.entries["uuid:1111"].expires = time.Now().Add(-time.Second)
+	r.mu.Unlock()
+
+	r.healthCheck()
+
+	if c := waitChange(t, r); c.Type != DeviceRemoved {
+		t.Errorf("expiry: got: %+v, want: uuid:1111 removed", c)
+	}
+
+	// Back again, and then unreachable.
+	r.alive(avTransportType, usn, location, 1800)
+	waitChange(t, r)
+
+	srv.Close()
+	r.healthCheck()
+
+	if c := waitChange(t, r); c.Type != DeviceRemoved {
+		t.Errorf("health check: got: %+v, want: uuid:1111 removed", c)
+	}
+
+	r.Close()
+	if _, ok := <-r.Changes(); ok {
+		t.Errorf("Close: the changes channel is still open")
+	}
+}
@@ -0,0 +1,349 @@
+package devices
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// KnownDevice - A media renderer remembered by the Store.
+type KnownDevice struct {
+	UDN          string `json:"udn"`
+	FriendlyName string `json:"friendly_name"`
+	// Alias - User defined name, e.g. "living-room".
+	Alias    string `json:"alias,omitempty"`
+	Favorite bool   `json:"favorite,omitempty"`
+	// Location - Last known description URL.
+	Location string `json:"location"`
+	// MAC - Last known hardware address, used to wake the device.
+	MAC      string    `json:"mac,omitempty"`
+	LastSeen time.Time `json:"last_seen"`
+}
+
+type storeData struct {
+	// Default and LastUsed hold UDNs.
+	Default  string        `json:"default,omitempty"`
+	LastUsed string        `json:"last_used,omitempty"`
+	Devices  []KnownDevice `json:"devices"`
+}
+
+// Store - Remembers the media renderers across runs, by UDN,
+// along with their aliases, the default and the last used one.
+type Store struct {
+	path string
+	data storeData
+	mu   sync.Mutex
+}
+
+// DefaultStorePath - The devices.json file in
+// the go2tv folder of the user config directory.
+func DefaultStorePath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("DefaultStorePath error: %w", err)
+	}
+
+	return filepath.Join(dir, "go2tv", "devices.json"), nil
+}
+
+// OpenStore - Load the store from the path. A missing file
+// results in an empty store. If path is empty, the
+// DefaultStorePath is used.
+func OpenStore(path string) (*Store, error) {
+	if path == "" {
+		var err error
+		if path, err = DefaultStorePath(); err != nil {
+			return nil, err
+		}
+	}
+
+	s := &Store{path: path}
+
+	b, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("OpenStore read error: %w", err)
+	}
+
+	if err := json.Unmarshal(b, &s.data); err != nil {
+		return nil, fmt.Errorf("OpenStore unmarshal error: %w", err)
+	}
+
+	return s, nil
+}
+
+// Save - Write the store to disk.
+func (s *Store) Save() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b, err := json.MarshalIndent(s.data, "", "  ")
+	if err != nil {
+		return fmt.Errorf("Store save marshal error: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o700); err != nil {
+		return fmt.Errorf("Store save mkdir error: %w", err)
+	}
+
+	// Write and rename so that a crash doesn't leave a truncated
+	// file behind. Each save gets its own temp file, as concurrent
+	// sessions may save at the same time.
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("Store save create error: %w", err)
+	}
+
+	_, err = tmp.Write(b)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("Store save write error: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("Store save rename error: %w", err)
+	}
+
+	return nil
+}
+
+// Remember - Add the devices to the store, or update
+// the friendly name and location of the known ones.
+func (s *Store) Remember(devs ...Device) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for _, d := range devs {
+		if k := s.find(d.UDN); k != nil {
+			k.FriendlyName = d.FriendlyName
+			k.Location = d.Location
+			k.LastSeen = now
+			if d.MAC != "" {
+				k.MAC = d.MAC
+			}
+			continue
+		}
+
+		s.data.Devices = append(s.data.Devices, KnownDevice{
+			UDN:          d.UDN,
+			FriendlyName: d.FriendlyName,
+			Location:     d.Location,
+			MAC:          d.MAC,
+			LastSeen:     now,
+		})
+	}
+}
+
+// Devices - Return the known devices, favorites first.
+func (s *Store) Devices() []KnownDevice {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list := make([]KnownDevice, len(s.data.Devices))
+	copy(list, s.data.Devices)
+
+	sort.SliceStable(list, func(i, j int) bool {
+		if list[i].Favorite != list[j].Favorite {
+			return list[i].Favorite
+		}
+		return list[i].FriendlyName < list[j].FriendlyName
+	})
+
+	return list
+}
+
+// SetAlias - Name a known device. Aliases are unique,
+// an empty alias removes the current one.
+func (s *Store) SetAlias(udn, alias string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k := s.find(udn)
+	if k == nil {
+		return errors.New("SetAlias: unknown device")
+	}
+
+	if alias != "" {
+		for _, other := range s.data.Devices {
+			if other.UDN != udn && strings.EqualFold(other.Alias, alias) {
+				return fmt.Errorf("SetAlias: alias %q already used by %s", alias, other.FriendlyName)
+			}
+		}
+	}
+
+	k.Alias = alias
+
+	return nil
+}
+
+// SetFavorite - Mark or unmark a known device as a favorite.
+func (s *Store) SetFavorite(udn string, favorite bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k := s.find(udn)
+	if k == nil {
+		return errors.New("SetFavorite: unknown device")
+	}
+
+	k.Favorite = favorite
+
+	return nil
+}
+
+// SetDefault - Use the known device when no device is asked for.
+func (s *Store) SetDefault(udn string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.find(udn) == nil {
+		return errors.New("SetDefault: unknown device")
+	}
+
+	s.data.Default = udn
+
+	return nil
+}
+
+// SetLastUsed - Record the device that was cast to last.
+func (s *Store) SetLastUsed(udn string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data.LastUsed = udn
+}
+
+// Lookup - Find a known device by alias, UDN or friendly name. An
+// empty name returns the default device or, if there is none,
+// the last used one.
+func (s *Store) Lookup(name string) (*KnownDevice, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if name == "" {
+		name = s.data.Default
+		if name == "" {
+			name = s.data.LastUsed
+		}
+		if name == "" {
+			return nil, false
+		}
+	}
+
+	for _, match := range []func(k *KnownDevice) bool{
+		func(k *KnownDevice) bool { return k.Alias != "" && strings.EqualFold(k.Alias, name) },
+		func(k *KnownDevice) bool {
+			return strings.TrimPrefix(k.UDN, "uuid:") == strings.TrimPrefix(name, "uuid:")
+		},
+		func(k *KnownDevice) bool { return strings.EqualFold(k.FriendlyName, name) },
+	} {
+		for i := range s.data.Devices {
+			if match(&s.data.Devices[i]) {
+				k := s.data.Devices[i]
+				return &k, true
+			}
+		}
+	}
+
+	return nil, false
+}
+
+// Resolve - Return the current details of a known device, looked up
+// as in Lookup. The cached location is checked first. If the device
+// is not there, the network is searched again in case it moved and,
+// if it's not found, it's woken up in case it's in standby. The
+// store is updated but not saved.
+func (s *Store) Resolve(ctx context.Context, client *http.Client, name string) (*Device, error) {
+	k, ok := s.Lookup(name)
+	if !ok {
+		return nil, fmt.Errorf("Resolve: unknown device %q", name)
+	}
+
+	if dev, err := loadDevice(ctx, client, k.Location); err == nil && dev.UDN == k.UDN {
+		s.Remember(*dev)
+		return dev, nil
+	}
+
+	// The cached location is stale, e.g. the
+	// media renderer got a new IP address.
+	res, err := SearchDevices(ctx, client, 1)
+	if err == nil {
+		s.Remember(res.Devices...)
+
+		for i := range res.Devices {
+			if res.Devices[i].UDN == k.UDN {
+				return &res.Devices[i], nil
+			}
+		}
+	}
+
+	// TVs in standby don't answer, nor show up in SSDP searches.
+	if k.MAC != "" {
+		if err := Wake(ctx, client, k.MAC, k.Location); err != nil {
+			return nil, fmt.Errorf("Resolve: %s is not available: %w", k.FriendlyName, err)
+		}
+
+		dev, err := loadDevice(ctx, client, k.Location)
+		if err != nil {
+			return nil, fmt.Errorf("Resolve error: %w", err)
+		}
+		s.Remember(*dev)
+		return dev, nil
+	}
+
+	return nil, fmt.Errorf("Resolve: %s is not available", k.FriendlyName)
+}
+
+// ResolveLocation - Load the device at the description URL and
+// remember it. If it doesn't respond and it's a known device, it's
+// resolved as in Resolve, which may wake it up. The store is
+// updated but not saved.
+func (s *Store) ResolveLocation(ctx context.Context, client *http.Client, location string) (*Device, error) {
+	dev, err := loadDevice(ctx, client, location)
+	if err == nil {
+		s.Remember(*dev)
+		return dev, nil
+	}
+
+	s.mu.Lock()
+	var udn string
+	for _, k := range s.data.Devices {
+		if k.Location == location {
+			udn = k.UDN
+			break
+		}
+	}
+	s.mu.Unlock()
+
+	if udn == "" {
+		return nil, fmt.Errorf("ResolveLocation error: %w", err)
+	}
+
+	return s.Resolve(ctx, client, udn)
+}
+
+// find - Must be called with the lock held.
+func (s *Store) find(udn string) *KnownDevice {
+	for i := range s.data.Devices {
+		if s.data.Devices[i].UDN == udn {
+			return &s.data.Devices[i]
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,170 @@
+package devices
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "go2tv", "devices.json")
+
+	s, err := OpenStore(path)
+	if err != nil {
+		t.Fatalf("Failed to call OpenStore due to %s", err.Error())
+	}
+
+	if len(s.Devices()) != 0 {
+		t.Errorf("OpenStore: got: %d devices for a missing file, want: 0", len(s.Devices()))
+	}
+
+	s.Remember(
+		Device{UDN: "uuid:1111", FriendlyName: "Living Room", Location: "http://192.168.1.50/dmr", MAC: "aa:bb:cc:dd:ee:ff"},
+		Device{UDN: "uuid:2222", FriendlyName: "Bedroom", Location: "http://192.168.1.51/dmr"},
+	)
+	// Moved, the MAC address is kept.
+	s.Remember(Device{UDN: "uuid:1111", FriendlyName: "Living Room", Location: "http://192.168.1.60/dmr"})
+
+	if err := s.SetAlias("uuid:1111", "tv"); err != nil {
+		t.Errorf("SetAlias: %v", err)
+	}
+	if err := s.SetAlias("uuid:2222", "TV"); err == nil {
+		t.Errorf("SetAlias: expected error for an alias already used")
+	}
+	if err := s.SetAlias("uuid:3333", "other"); err == nil {
+		t.Errorf("SetAlias: expected error for an unknown device")
+	}
+	if err := s.SetFavorite("uuid:1111", true); err != nil {
+		t.Errorf("SetFavorite: %v", err)
+	}
+	s.SetLastUsed("uuid:2222")
+
+	if err := s.Save(); err != nil {
+		t.Fatalf("Failed to call Save due to %s", err.Error())
+	}
+
+	s, err = OpenStore(path)
+	if err != nil {
+		t.Fatalf("Failed to call OpenStore due to %s", err.Error())
+	}
+
+	devs := s.Devices()
+	if len(devs) != 2 || devs[0].UDN != "uuid:1111" || !devs[0].Favorite {
+		t.Fatalf("Devices: got: %+v, want: the favorite uuid:1111 first", devs)
+	}
+
+	if devs[0].Location != "http://192.168.1.60/dmr" || devs[0].MAC != "aa:bb:cc:dd:ee:ff" {
+		t.Errorf("Remember: got: %+v, want: the new location and the old MAC", devs[0])
+	}
+
+	tt := []struct {
+		name    string
+		input   string
+		wantUDN string
+	}{
+		{`Alias`, "tv", "uuid:1111"},
+		{`UDN`, "2222", "uuid:2222"},
+		{`Friendly name`, "bedroom", "uuid:2222"},
+		{`Last used`, "", "uuid:2222"},
+		{`Unknown`, "kitchen", ""},
+	}
+
+	for _, tc := range tt {
+		k, ok := s.Lookup(tc.input)
+		if tc.wantUDN == "" {
+			if ok {
+				t.Errorf("%s: got: %s, want: not found", tc.name, k.UDN)
+			}
+			continue
+		}
+
+		if !ok || k.UDN != tc.wantUDN {
+			t.Errorf("%s: got: %v, want: %s.", tc.name, k, tc.wantUDN)
+		}
+	}
+
+	// The default device takes precedence over the last used one.
+	if err := s.SetDefault("uuid:1111"); err != nil {
+		t.Errorf("SetDefault: %v", err)
+	}
+	if k, ok := s.Lookup(""); !ok || k.UDN != "uuid:1111" {
+		t.Errorf("Default: got: %v, want: uuid:1111", k)
+	}
+}
+
+func TestStoreResolve(t *testing.T) {
+	srv := newDescriptionServer(map[string]string{
+		"/dmr": rendererDescription("uuid:1111", "Living Room"),
+	})
+	defer srv.Close()
+
+	s, err := OpenStore(filepath.Join(t.TempDir(), "devices.json"))
+	if err != nil {
+		t.Fatalf("Failed to call OpenStore due to %s", err.Error())
+	}
+
+	dev, err := s.ResolveLocation(context.Background(), nil, srv.URL+"/dmr")
+	if err != nil {
+		t.Fatalf("Failed to call ResolveLocation due to %s", err.Error())
+	}
+
+	if k, ok := s.Lookup("Living Room"); !ok || k.Location != dev.Location {
+		t.Errorf("ResolveLocation: got: %v, want: the device remembered", k)
+	}
+
+	dev, err = s.Resolve(context.Background(), nil, "uuid:1111")
+	if err != nil {
+		t.Fatalf("Failed to call Resolve due to %s", err.Error())
+	}
+
+	if dev.Location != srv.URL+"/dmr" {
+		t.Errorf("Resolve: got: %s, want: %s", dev.Location, srv.URL+"/dmr")
+	}
+
+	if _, err := s.Resolve(context.Background(), nil, "kitchen"); err == nil {
+		t.Errorf("Resolve: expected error for an unknown device")
+	}
+}
+
+func TestStoreConcurrentSave(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "devices.json")
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			s, err := OpenStore(path)
+			if err != nil {
+				errs <- err
+				return
+			}
+
+			s.Remember(Device{UDN: fmt.Sprintf("uuid:%d", i), Location: "http://192.168.1.50/dmr"})
+			errs <- s.Save()
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Errorf("Save: %v", err)
+		}
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("ReadDir: %v", err)
+	}
+
+	if len(entries) != 1 {
+		t.Errorf("Save: got: %d files, want: 1, no temp files left", len(entries))
+	}
+}
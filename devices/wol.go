@@ -0,0 +1,255 @@
+package devices
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"regexp"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/chyroc/go2tv/soapcalls"
+	"github.com/pkg/errors"
+)
+
+const (
+	// wakeTimeout - How long to wait for a media renderer to wake up,
+	// unless the ctx passed to Wake has an earlier deadline.
+	wakeTimeout = 60 * time.Second
+
+	wakeMinBackoff = 500 * time.Millisecond
+	wakeMaxBackoff = 5 * time.Second
+)
+
+var macRe = regexp.MustCompile(`(?i)\b([0-9a-f]{1,2}[:-]){5}[0-9a-f]{1,2}\b`)
+
+// Wake - Send a Wake-on-LAN magic packet to the media renderer and wait,
+// with backoff, until its description URL responds again.
+func Wake(ctx context.Context, client *http.Client, mac, location string) error {
+	hw, err := net.ParseMAC(mac)
+	if err != nil {
+		return fmt.Errorf("Wake parse MAC error: %w", err)
+	}
+
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, wakeTimeout)
+		defer cancel()
+	}
+
+	backoff := wakeMinBackoff
+	for {
+		// The packet is repeated on every attempt,
+		// UDP broadcasts are easily lost.
+		if err := SendMagicPacket(hw, location); err != nil {
+			return fmt.Errorf("Wake error: %w", err)
+		}
+
+		reqCtx, cancel := context.WithTimeout(ctx, backoff)
+		_, err := soapcalls.GetDeviceDescriptionContext(reqCtx, client, location)
+		cancel()
+		if err == nil {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("Wake error: media renderer did not wake up: %w", ctx.Err())
+		case <-time.After(backoff):
+		}
+
+		if backoff *= 2; backoff > wakeMaxBackoff {
+			backoff = wakeMaxBackoff
+		}
+	}
+}
+
+// SendMagicPacket - Broadcast a Wake-on-LAN magic packet for the MAC
+// address. If location is not empty, the packet is also sent to the
+// directed broadcast address of the local network of its host.
+func SendMagicPacket(mac net.HardwareAddr, location string) error {
+	if len(mac) != 6 {
+		return errors.New("SendMagicPacket: invalid MAC address")
+	}
+
+	packet := magicPacket(mac)
+
+	targets := []string{"255.255.255.255:9"}
+	if u, err := url.Parse(location); err == nil {
+		if bcast := directedBroadcast(net.ParseIP(u.Hostname())); bcast != nil {
+			targets = append(targets, net.JoinHostPort(bcast.String(), "9"))
+		}
+	}
+
+	var sent bool
+	var lastErr error
+	for _, t := range targets {
+		conn, err := net.Dial("udp4", t)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		_, err = conn.Write(packet)
+		conn.Close()
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		sent = true
+	}
+
+	if !sent {
+		return fmt.Errorf("SendMagicPacket error: %w", lastErr)
+	}
+
+	return nil
+}
+
+// magicPacket - 6 bytes of 0xff followed
+// by 16 repetitions of the MAC address.
+func magicPacket(mac net.HardwareAddr) []byte {
+	packet := bytes.Repeat([]byte{0xff}, 6)
+	for i := 0; i < 16; i++ {
+		packet = append(packet, mac...)
+	}
+
+	return packet
+}
+
+// directedBroadcast - The broadcast address of the network of the
+// local interface the ip is on. Returns nil if it's on none of them.
+func directedBroadcast(ip net.IP) net.IP {
+	if ip = ip.To4(); ip == nil {
+		return nil
+	}
+
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return nil
+	}
+
+	for _, addr := range addrs {
+		ipnet, ok := addr.(*net.IPNet)
+		if !ok || !ipnet.Contains(ip) {
+			continue
+		}
+
+		if bcast := broadcastAddr(ipnet); bcast != nil {
+			return bcast
+		}
+	}
+
+	return nil
+}
+
+// broadcastAddr - The broadcast address of an IPv4 network.
+func broadcastAddr(ipnet *net.IPNet) net.IP {
+	ip := ipnet.IP.To4()
+	mask := ipnet.Mask
+	if len(mask) == net.IPv6len {
+		mask = mask[12:]
+	}
+	if ip == nil || len(mask) != net.IPv4len {
+		return nil
+	}
+
+	bcast := make(net.IP, net.IPv4len)
+	for i := range ip {
+		bcast[i] = ip[i] | ^mask[i]
+	}
+
+	return bcast
+}
+
+// lookupMAC - Find the MAC address of the host of the location in the
+// ARP/neighbor table. The table entry exists right after talking to
+// the host, so this is called once its description is fetched.
+func lookupMAC(ctx context.Context, location string) string {
+	u, err := url.Parse(location)
+	if err != nil {
+		return ""
+	}
+
+	ip := net.ParseIP(u.Hostname())
+	if ip == nil || ip.To4() == nil {
+		return ""
+	}
+
+	if mac := lookupProcARP(ip.String()); mac != "" {
+		return mac
+	}
+
+	// Not on Linux, the arp tool has us covered on macOS and Windows.
+	// -n skips the reverse DNS lookups, Windows never does those.
+	flag := "-n"
+	if runtime.GOOS == "windows" {
+		flag = "-a"
+	}
+
+	out, err := exec.CommandContext(ctx, "arp", flag, ip.String()).Output()
+	if err != nil {
+		return ""
+	}
+
+	for _, line := range strings.Split(string(out), "\n") {
+		if !strings.Contains(line, ip.String()) {
+			continue
+		}
+		if m := macRe.FindString(line); m != "" {
+			return normalizeMAC(m)
+		}
+	}
+
+	return ""
+}
+
+func lookupProcARP(ip string) string {
+	f, err := os.Open("/proc/net/arp")
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	// IP address  HW type  Flags  HW address  Mask  Device
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 4 || fields[0] != ip {
+			continue
+		}
+
+		// Incomplete entries have a zero address.
+		if fields[3] == "00:00:00:00:00:00" {
+			return ""
+		}
+
+		return normalizeMAC(fields[3])
+	}
+
+	return ""
+}
+
+// normalizeMAC - macOS drops the leading zeros
+// and Windows uses dashes.
+func normalizeMAC(mac string) string {
+	parts := strings.FieldsFunc(mac, func(r rune) bool { return r == ':' || r == '-' })
+	if len(parts) != 6 {
+		return ""
+	}
+
+	for i, p := range parts {
+		if len(p) == 1 {
+			parts[i] = "0" + p
+		}
+	}
+
+	return strings.ToLower(strings.Join(parts, ":"))
+}
@@ -0,0 +1,73 @@
+package devices
+
+import (
+	"bytes"
+	"net"
+	"testing"
+)
+
+func TestNormalizeMAC(t *testing.T) {
+	tt := []struct {
+		input string
+		want  string
+	}{
+		{"AA:BB:CC:DD:EE:FF", "aa:bb:cc:dd:ee:ff"},
+		{"a:b:c:d:e:f", "0a:0b:0c:0d:0e:0f"},
+		{"aa-bb-cc-dd-ee-ff", "aa:bb:cc:dd:ee:ff"},
+		{"aa:bb:cc", ""},
+	}
+
+	for _, tc := range tt {
+		if out := normalizeMAC(tc.input); out != tc.want {
+			t.Errorf("%s: got: %s, want: %s.", tc.input, out, tc.want)
+		}
+	}
+}
+
+func TestMagicPacket(t *testing.T) {
+	mac, _ := net.ParseMAC("aa:bb:cc:dd:ee:ff")
+	packet := magicPacket(mac)
+
+	if len(packet) != 102 {
+		t.Fatalf("magicPacket: got: %d bytes, want: 102", len(packet))
+	}
+
+	if !bytes.Equal(packet[:6], bytes.Repeat([]byte{0xff}, 6)) {
+		t.Errorf("magicPacket: got header: %x, want: ffffffffffff", packet[:6])
+	}
+
+	for i := 0; i < 16; i++ {
+		if chunk := packet[6+i*6 : 12+i*6]; !bytes.Equal(chunk, mac) {
+			t.Errorf("magicPacket: repetition #%d: got: %x, want: %x", i, chunk, []byte(mac))
+		}
+	}
+}
+
+func TestBroadcastAddr(t *testing.T) {
+	tt := []struct {
+		cidr string
+		want string
+	}{
+		{"192.168.1.50/24", "192.168.1.255"},
+		{"10.0.5.7/16", "10.0.255.255"},
+		{"172.16.3.4/22", "172.16.3.255"},
+		{"192.168.1.50/30", "192.168.1.51"},
+	}
+
+	for _, tc := range tt {
+		ip, ipnet, err := net.ParseCIDR(tc.cidr)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.cidr, err)
+		}
+		ipnet.IP = ip
+
+		if out := broadcastAddr(ipnet); out.String() != tc.want {
+			t.Errorf("%s: got: %s, want: %s.", tc.cidr, out, tc.want)
+		}
+	}
+
+	_, v6, _ := net.ParseCIDR("fe80::1/64")
+	if out := broadcastAddr(v6); out != nil {
+		t.Errorf("fe80::1/64: got: %s, want: nil", out)
+	}
+}
@@ -0,0 +1,83 @@
+package httphandlers
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/chyroc/go2tv/utils"
+)
+
+// AccessPolicy - Restricts who can fetch from the HTTPserver. Requests
+// must come from one of the allowed IPs and their path must start with
+// the token. Everyone else gets a 403.
+type AccessPolicy struct {
+	// AllowedIPs - Usually the IPs of the media renderer.
+	AllowedIPs []string
+	// Token - An unguessable per-session path segment.
+	Token string
+}
+
+// NewAccessPolicy - Create a policy allowing the host of the media
+// renderer description URL, with a random token.
+func NewAccessPolicy(dmrURL string) (*AccessPolicy, error) {
+	u, err := url.Parse(dmrURL)
+	if err != nil {
+		return nil, fmt.Errorf("NewAccessPolicy parse error: %w", err)
+	}
+
+	ips, err := net.DefaultResolver.LookupHost(context.Background(), u.Hostname())
+	if err != nil {
+		return nil, fmt.Errorf("NewAccessPolicy lookup error: %w", err)
+	}
+
+	token, err := utils.RandomString()
+	if err != nil {
+		return nil, fmt.Errorf("NewAccessPolicy error: %w", err)
+	}
+
+	return &AccessPolicy{
+		AllowedIPs: ips,
+		Token:      token,
+	}, nil
+}
+
+// PathPrefix - The prefix of the paths allowed by the policy.
+func (a *AccessPolicy) PathPrefix() string {
+	if a == nil || a.Token == "" {
+		return ""
+	}
+
+	return "/" + a.Token
+}
+
+func (a *AccessPolicy) allows(r *http.Request) bool {
+	if a.Token != "" && !strings.HasPrefix(r.URL.Path, a.PathPrefix()+"/") {
+		return false
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return false
+	}
+
+	ip := net.ParseIP(host)
+	for _, allowed := range a.AllowedIPs {
+		if ip != nil && ip.Equal(net.ParseIP(allowed)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// SetAccessPolicy - Restrict the server to the policy.
+// A nil policy lets everyone in.
+func (s *HTTPserver) SetAccessPolicy(p *AccessPolicy) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.policy = p
+}
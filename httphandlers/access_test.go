@@ -0,0 +1,57 @@
+package httphandlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAccessPolicy(t *testing.T) {
+	s := NewServer("127.0.0.1:0")
+	s.SetAccessPolicy(&AccessPolicy{
+		AllowedIPs: []string{"192.168.1.50"},
+		Token:      "TOKEN",
+	})
+
+	if _, err := s.AddItem("/TOKEN/media.mp4", []byte("go2tv"), "video/mp4"); err != nil {
+		t.Fatalf("AddItem: %v", err)
+	}
+
+	tt := []struct {
+		name       string
+		remoteAddr string
+		path       string
+		want       int
+	}{
+		{
+			`Media renderer with token`,
+			"192.168.1.50:40000",
+			"/TOKEN/media.mp4",
+			http.StatusOK,
+		},
+		{
+			`Media renderer without token`,
+			"192.168.1.50:40000",
+			"/media.mp4",
+			http.StatusForbidden,
+		},
+		{
+			`Other host with token`,
+			"192.168.1.51:40000",
+			"/TOKEN/media.mp4",
+			http.StatusForbidden,
+		},
+	}
+
+	for _, tc := range tt {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, tc.path, nil)
+		r.RemoteAddr = tc.remoteAddr
+
+		s.ServeHTTP(w, r)
+
+		if w.Result().StatusCode != tc.want {
+			t.Errorf("%s: got: %s, want: %d.", tc.name, w.Result().Status, tc.want)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package httphandlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/chyroc/go2tv/soapcalls"
+)
+
+type fakeScreen struct {
+	mu     sync.Mutex
+	msgs   []string
+	closed bool
+}
+
+func (f *fakeScreen) EmitMsg(s string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.msgs = append(f.msgs, s)
+}
+
+func (f *fakeScreen) Fini() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.closed = true
+}
+
+func (f *fakeScreen) isClosed() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.closed
+}
+
+func lastChange(state string) string {
+	return `<e:propertyset xmlns:e="urn:schemas-upnp-org:event-1-0"><e:property><LastChange>` +
+		`<Event xmlns="urn:schemas-upnp-org:metadata-1-0/AVT/"><InstanceID val="0">` +
+		`<TransportState val="` + state + `"/><CurrentTrackDuration val="0:01:00"/>` +
+		`</InstanceID></Event></LastChange></e:property></e:propertyset>`
+}
+
+func TestCallbackStoppedUnsubscribesAll(t *testing.T) {
+	var unsubscribed []string
+	renderer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "SUBSCRIBE":
+			w.Header().Set("SID", "uuid:"+strings.TrimPrefix(r.URL.Path, "/"))
+			w.Header().Set("TIMEOUT", "Second-300")
+		case "UNSUBSCRIBE":
+			unsubscribed = append(unsubscribed, r.Header.Get("SID"))
+		}
+	}))
+	defer renderer.Close()
+
+	tv := &soapcalls.TVPayload{
+		EventURL:                 renderer.URL + "/avt",
+		RenderingControlEventURL: renderer.URL + "/rc",
+		CallbackURL:              "http://127.0.0.1/callback",
+		CurrentTimers:            make(map[string]*time.Timer),
+	}
+
+	if err := tv.SubscribeSoapCall(""); err != nil {
+		t.Fatalf("SubscribeSoapCall: %v", err)
+	}
+	if err := tv.SubscribeRenderingControlSoapCall(); err != nil {
+		t.Fatalf("SubscribeRenderingControlSoapCall: %v", err)
+	}
+
+	if len(tv.CurrentTimers) != 2 {
+		t.Fatalf("got: %d timers, want: 2", len(tv.CurrentTimers))
+	}
+
+	s := NewServer("127.0.0.1:0")
+	scr := &fakeScreen{}
+	handler := s.callbackHandler(tv, scr)
+
+	for _, state := range []string{"PLAYING", "STOPPED"} {
+		req := httptest.NewRequest("NOTIFY", "/callback", strings.NewReader(lastChange(state)))
+		req.Header.Set("SID", "uuid:avt")
+		handler(httptest.NewRecorder(), req)
+	}
+
+	// The events are acted on in the background.
+	for deadline := time.Now().Add(5 * time.Second); !scr.isClosed() && time.Now().Before(deadline); {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if !scr.isClosed() {
+		t.Fatalf("STOPPED: screen was not closed")
+	}
+
+	if len(tv.CurrentTimers) != 0 {
+		t.Errorf("STOPPED: got: %d timers left, want: 0", len(tv.CurrentTimers))
+	}
+
+	if len(unsubscribed) != 2 {
+		t.Errorf("STOPPED: got: %v unsubscribed, want: uuid:avt and uuid:rc", unsubscribed)
+	}
+}
+
+func TestCallbackInitialEvent(t *testing.T) {
+	tv := &soapcalls.TVPayload{}
+	tv.CreateMRstate("avt")
+
+	s := NewServer("127.0.0.1:0")
+	scr := &fakeScreen{}
+
+	req := httptest.NewRequest("NOTIFY", "/callback", strings.NewReader(lastChange("STOPPED")))
+	req.Header.Set("SID", "uuid:avt")
+	s.callbackHandler(tv, scr)(httptest.NewRecorder(), req)
+
+	st := tv.GetAVTransportState()
+	if st.CurrentTrackDuration != "0:01:00" || st.TransportState != "STOPPED" {
+		t.Errorf("Initial event: got: %+v, want: the evented variables", st)
+	}
+
+	if scr.isClosed() {
+		t.Errorf("Initial event: the STOPPED state was acted on")
+	}
+}
@@ -2,24 +2,40 @@ package httphandlers
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"html"
 	"io"
+	"log"
 	"net"
 	"net/http"
 	"net/url"
-	"os"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/chyroc/go2tv/soapcalls"
+	"github.com/chyroc/go2tv/urlstreamer"
 	"github.com/chyroc/go2tv/utils"
 )
 
 // HTTPserver - new http.Server instance.
 type HTTPserver struct {
-	http *http.Server
-	mux  *http.ServeMux
+	http   *http.Server
+	routes map[string]*route
+	policy *AccessPolicy
+	ln     net.Listener
+	// accessLog - If set, every request is logged.
+	accessLog *log.Logger
+	stats     Stats
+	// mediaHits - Requests per media path, to count reconnects.
+	mediaHits map[string]int
+	// done - Closed once the server stops serving.
+	done chan struct{}
+	// serveErr - Why the server stopped serving, if it
+	// wasn't closed or shut down. Set before done is closed.
+	serveErr error
+	mu       sync.Mutex
 }
 
 // Screen interface.
@@ -38,6 +54,18 @@ func Close(scr Screen) {
 	scr.Fini()
 }
 
+// refresher - Screens that can redraw themselves
+// without changing the status message.
+type refresher interface {
+	Refresh()
+}
+
+func refresh(scr Screen) {
+	if r, ok := scr.(refresher); ok {
+		r.Refresh()
+	}
+}
+
 // ServeFiles - Start HTTP server and serve the files.
 func (s *HTTPserver) ServeFiles(serverStarted chan<- struct{}, media, subtitles interface{},
 	tvpayload *soapcalls.TVPayload, screen Screen) error {
@@ -57,34 +85,59 @@ func (s *HTTPserver) ServeFiles(serverStarted chan<- struct{}, media, subtitles
 		return fmt.Errorf("failed to parse CallbackURL: %w", err)
 	}
 
-	s.mux.HandleFunc(mURL.Path, s.serveMediaHandler(tvpayload, media))
-	s.mux.HandleFunc(sURL.Path, s.serveSubtitlesHandler(subtitles))
-	s.mux.HandleFunc(callbackURL.Path, s.callbackHandler(tvpayload, screen))
+	if _, err := s.AddItem(mURL.Path, media, tvpayload.MediaType); err != nil {
+		closeMedia(media)
+		closeMedia(subtitles)
+		return err
+	}
+	if _, err := s.AddSubtitles(sURL.Path, subtitles); err != nil {
+		closeMedia(subtitles)
+		return err
+	}
+	if _, err := s.Handle(callbackURL.Path, s.callbackHandler(tvpayload, screen)); err != nil {
+		return err
+	}
 
-	ln, err := net.Listen("tcp", s.http.Addr)
-	if err != nil {
-		return fmt.Errorf("server listen error: %w", err)
+	// The server may have been started
+	// already, to serve other items.
+	if err := s.Start(); err != nil {
+		s.closeRoutes()
+		return err
 	}
 
 	serverStarted <- struct{}{}
-	s.http.Serve(ln)
+	<-s.done
 
-	return nil
+	return s.serveErr
 }
 
-func (s *HTTPserver) serveMediaHandler(tv *soapcalls.TVPayload, media interface{}) http.HandlerFunc {
-	return func(w http.ResponseWriter, req *http.Request) {
-		serveContent(w, req, tv, media, true)
+// AddMedia - Serve an additional media item while the server
+// is running. Used to register the queued media items before
+// handing them over to the media renderer.
+func (s *HTTPserver) AddMedia(item *soapcalls.QueueItem, media interface{}) error {
+	mURL, err := url.Parse(item.MediaURL)
+	if err != nil {
+		return fmt.Errorf("failed to parse MediaURL: %w", err)
 	}
+
+	_, err = s.AddItem(mURL.Path, media, item.MediaType)
+	return err
 }
 
-func (s *HTTPserver) serveSubtitlesHandler(subs interface{}) http.HandlerFunc {
-	return func(w http.ResponseWriter, req *http.Request) {
-		serveContent(w, req, nil, subs, false)
-	}
+// HasPath - Check if a path is already being served.
+func (s *HTTPserver) HasPath(path string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.routes[path]
+	return ok
 }
 
 func (s *HTTPserver) callbackHandler(tv *soapcalls.TVPayload, screen Screen) http.HandlerFunc {
+	// Acting on a state change may take several SOAP calls, so it's
+	// done in the background, one event at a time, and the media
+	// renderer gets its event acknowledged right away.
+	var stateMu sync.Mutex
+
 	return func(w http.ResponseWriter, req *http.Request) {
 		reqParsed, _ := io.ReadAll(req.Body)
 		sidVal, sidExists := req.Header["Sid"]
@@ -104,60 +157,136 @@ func (s *HTTPserver) callbackHandler(tv *soapcalls.TVPayload, screen Screen) htt
 		// Apparently we should ignore the first message
 		// On some media renderers we receive a STOPPED message
 		// even before we start streaming.
-		seq, err := soapcalls.GetSequence(uuid)
+		seq, err := tv.GetSequence(uuid)
 		if err != nil {
 			http.NotFound(w, req)
 			return
 		}
 
+		reqParsedUnescape := html.UnescapeString(string(reqParsed))
+		event, err := soapcalls.EventNotifyParser(reqParsedUnescape)
+
 		if seq == 0 {
-			soapcalls.IncreaseSequence(uuid)
+			// The initial event carries all the evented variables
+			// though, so we merge it into the session state. We
+			// just don't act on its TransportState.
+			if err == nil {
+				tv.UpdateLastChange(event, uuid)
+				refresh(screen)
+			} else {
+				tv.IncreaseSequence(uuid)
+			}
 			fmt.Fprintf(w, "OK\n")
 			return
 		}
 
-		reqParsedUnescape := html.UnescapeString(string(reqParsed))
-		previousstate, newstate, err := soapcalls.EventNotifyParser(reqParsedUnescape)
 		if err != nil {
 			http.NotFound(w, req)
 			return
 		}
 
-		if !soapcalls.UpdateMRstate(previousstate, newstate, uuid) {
+		if !tv.UpdateLastChange(event, uuid) {
 			http.NotFound(w, req)
 			return
 		}
 
-		switch newstate {
-		case "PLAYING":
-			Emit(screen, "Playing")
-		case "PAUSED_PLAYBACK":
-			Emit(screen, "Paused")
-		case "STOPPED":
-			Emit(screen, "Stopped")
-			tv.UnsubscribeSoapCall(uuid)
-			Close(screen)
+		// Events that don't carry a TransportState
+		// only update the session state.
+		if event.TransportState == nil {
+			if len(event.Volume) > 0 || len(event.Mute) > 0 {
+				refresh(screen)
+			}
+			return
+		}
+
+		state := event.TransportState.Value
+		go func() {
+			stateMu.Lock()
+			defer stateMu.Unlock()
+			onTransportState(tv, screen, state)
+		}()
+	}
+}
+
+func onTransportState(tv *soapcalls.TVPayload, screen Screen, state string) {
+	switch state {
+	case "PLAYING":
+		// The media renderer may have moved on
+		// to the preloaded item on its own.
+		tv.SyncQueueSoapCall()
+		Emit(screen, "Playing")
+	case "PAUSED_PLAYBACK":
+		Emit(screen, "Paused")
+	case "STOPPED":
+		if advanced, _ := tv.AdvanceQueueSoapCall(); advanced {
+			Emit(screen, "Waiting for status...")
+			return
 		}
+		Emit(screen, "Stopped")
+		// The RenderingControl subscription
+		// goes away along with the AVTransport one.
+		tv.UnsubscribeAllSoapCall()
+		Close(screen)
 	}
 }
 
-// StopServeFiles .
+// StopServeFiles - Stop the server right away, cutting off
+// any media renderer still transferring media.
 func (s *HTTPserver) StopServeFiles() {
 	s.http.Close()
+	s.closeRoutes()
+}
+
+// Shutdown - Stop accepting new requests and wait for the in-flight
+// ones to finish. If ctx expires first, the remaining connections are
+// cut. Either way, the media of all the routes are closed.
+func (s *HTTPserver) Shutdown(ctx context.Context) error {
+	err := s.http.Shutdown(ctx)
+	if err != nil {
+		s.http.Close()
+	}
+
+	s.closeRoutes()
+
+	if err != nil {
+		return fmt.Errorf("server shutdown error: %w", err)
+	}
+
+	return nil
+}
+
+// closeRoutes - Remove all the routes, closing their media.
+func (s *HTTPserver) closeRoutes() {
+	s.mu.Lock()
+	routes := s.routes
+	s.routes = make(map[string]*route)
+	s.mu.Unlock()
+
+	for _, rt := range routes {
+		closeMedia(rt.media)
+	}
+}
+
+// closeMedia - Close the media, if it can be closed.
+func closeMedia(media interface{}) {
+	if c, ok := media.(io.Closer); ok {
+		c.Close()
+	}
 }
 
 // NewServer - create a new HTTP server.
 func NewServer(a string) *HTTPserver {
-	mux := http.NewServeMux()
-	srv := HTTPserver{
-		http: &http.Server{Addr: a, Handler: mux},
-		mux:  mux,
+	srv := &HTTPserver{
+		routes:    make(map[string]*route),
+		done:      make(chan struct{}),
+		mediaHits: make(map[string]int),
 	}
+	srv.http = &http.Server{Addr: a, Handler: srv}
 
-	return &srv
+	return srv
 }
 
-func serveContent(w http.ResponseWriter, r *http.Request, tv *soapcalls.TVPayload, s interface{}, isMedia bool) {
+func serveContent(w http.ResponseWriter, r *http.Request, mediaType string, s interface{}, isMedia bool) {
 	respHeader := w.Header()
 	if isMedia {
 		respHeader["transferMode.dlna.org"] = []string{"Streaming"}
@@ -166,13 +295,14 @@ func serveContent(w http.ResponseWriter, r *http.Request, tv *soapcalls.TVPayloa
 		respHeader["transferMode.dlna.org"] = []string{"Interactive"}
 	}
 
-	var mediaType string
-	if tv != nil {
-		mediaType = tv.MediaType
-	}
-
 	switch f := s.(type) {
 	case string:
+		serveFile(w, r, mediaType, f, 0)
+
+	case *timedFile:
+		serveFile(w, r, mediaType, f.path, f.duration)
+
+	case []byte:
 		if r.Header.Get("getcontentFeatures.dlna.org") == "1" {
 			contentFeatures, err := utils.BuildContentFeatures(mediaType, "01", false)
 			if err != nil {
@@ -183,23 +313,12 @@ func serveContent(w http.ResponseWriter, r *http.Request, tv *soapcalls.TVPayloa
 			respHeader["contentFeatures.dlna.org"] = []string{contentFeatures}
 		}
 
-		filePath, err := os.Open(f)
-		if err != nil {
-			http.NotFound(w, r)
-			return
-		}
-		defer filePath.Close()
-
-		fileStat, err := filePath.Stat()
-		if err != nil {
-			http.NotFound(w, r)
-			return
-		}
+		bReader := bytes.NewReader(f)
 
 		name := strings.TrimLeft(r.URL.Path, "/")
-		http.ServeContent(w, r, name, fileStat.ModTime(), filePath)
+		http.ServeContent(w, r, name, time.Now(), bReader)
 
-	case []byte:
+	case *Spool:
 		if r.Header.Get("getcontentFeatures.dlna.org") == "1" {
 			contentFeatures, err := utils.BuildContentFeatures(mediaType, "01", false)
 			if err != nil {
@@ -210,10 +329,20 @@ func serveContent(w http.ResponseWriter, r *http.Request, tv *soapcalls.TVPayloa
 			respHeader["contentFeatures.dlna.org"] = []string{contentFeatures}
 		}
 
-		bReader := bytes.NewReader(f)
+		serveSpool(w, r, f)
 
-		name := strings.TrimLeft(r.URL.Path, "/")
-		http.ServeContent(w, r, name, time.Now(), bReader)
+	case *urlstreamer.RangedURL:
+		if r.Header.Get("getcontentFeatures.dlna.org") == "1" {
+			contentFeatures, err := utils.BuildContentFeatures(mediaType, "01", false)
+			if err != nil {
+				http.NotFound(w, r)
+				return
+			}
+
+			respHeader["contentFeatures.dlna.org"] = []string{contentFeatures}
+		}
+
+		serveRanged(w, r, f)
 
 	case io.ReadCloser:
 		if r.Header.Get("getcontentFeatures.dlna.org") == "1" {
@@ -0,0 +1,49 @@
+package httphandlers
+
+import (
+	"io"
+	"net/http"
+
+	"github.com/chyroc/go2tv/urlstreamer"
+)
+
+// proxiedHeaders - The upstream response headers
+// the media renderers need to play and seek.
+var proxiedHeaders = []string{
+	"Accept-Ranges",
+	"Content-Length",
+	"Content-Range",
+	"Content-Type",
+	"Last-Modified",
+	"ETag",
+}
+
+// serveRanged - Forward the request, along with its Range header,
+// to the server of the media URL and relay the response. Each
+// request of the media renderer opens its own upstream request.
+func serveRanged(w http.ResponseWriter, r *http.Request, u *urlstreamer.RangedURL) {
+	method := http.MethodGet
+	if r.Method == http.MethodHead {
+		method = http.MethodHead
+	}
+
+	resp, err := u.Do(r.Context(), method, r.Header.Get("Range"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+
+	respHeader := w.Header()
+	for _, h := range proxiedHeaders {
+		if v := resp.Header.Get(h); v != "" {
+			respHeader.Set(h, v)
+		}
+	}
+
+	w.WriteHeader(resp.StatusCode)
+
+	if method == http.MethodGet {
+		io.Copy(w, resp.Body)
+	}
+}
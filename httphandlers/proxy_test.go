@@ -0,0 +1,54 @@
+package httphandlers
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chyroc/go2tv/urlstreamer"
+)
+
+func TestServeRanged(t *testing.T) {
+	data := bytes.Repeat([]byte("go2tv"), 1000)
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "media.mp4", time.Now(), bytes.NewReader(data))
+	}))
+	defer upstream.Close()
+
+	u, err := urlstreamer.NewRangedURL(context.Background(), upstream.URL+"/media.mp4")
+	if err != nil {
+		t.Fatalf("NewRangedURL: %v", err)
+	}
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/media.mp4", nil)
+	r.Header.Set("Range", "bytes=100-199")
+	r.Header.Set("getcontentFeatures.dlna.org", "1")
+
+	serveContent(w, r, "video/mp4", u, true)
+
+	if w.Result().StatusCode != http.StatusPartialContent {
+		t.Errorf("Ranged request: got: %s, want: %d.", w.Result().Status, http.StatusPartialContent)
+	}
+
+	if got := w.Result().Header.Get("Content-Range"); got != "bytes 100-199/5000" {
+		t.Errorf("Ranged request: got: %s, want: bytes 100-199/5000", got)
+	}
+
+	if got := w.Result().Header.Get("Content-Type"); got != "video/mp4" {
+		t.Errorf("Ranged request: got: %s, want: video/mp4", got)
+	}
+
+	if !bytes.Equal(w.Body.Bytes(), data[100:200]) {
+		t.Errorf("Ranged request: got: %d bytes, want: 100 bytes", w.Body.Len())
+	}
+
+	if got := strings.Join(w.Result().Header["contentFeatures.dlna.org"], ""); !strings.Contains(got, "DLNA.ORG_OP=01") {
+		t.Errorf("Ranged request: got: %s, want: DLNA.ORG_OP=01", got)
+	}
+}
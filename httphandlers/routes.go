@@ -0,0 +1,165 @@
+package httphandlers
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+)
+
+// route - A path served by the HTTPserver.
+type route struct {
+	handler http.HandlerFunc
+	// media - The media served on the route, if any.
+	media   interface{}
+	isMedia bool
+}
+
+// ServeHTTP - Dispatch the request to the route of its path.
+func (s *HTTPserver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	rt, ok := s.routes[r.URL.Path]
+	policy := s.policy
+	s.mu.Unlock()
+
+	sw := &statsWriter{ResponseWriter: w}
+	start := time.Now()
+	defer func() {
+		s.record(newAccessLogEntry(r, sw, start), ok && rt.isMedia)
+	}()
+
+	if policy != nil && !policy.allows(r) {
+		http.Error(sw, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return
+	}
+
+	if !ok {
+		http.NotFound(sw, r)
+		return
+	}
+
+	rt.handler(sw, r)
+}
+
+// Start - Start listening and serving in the background. Routes can
+// be added and removed before and after the server is started.
+// Starting a started server is a no-op.
+func (s *HTTPserver) Start() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.ln != nil {
+		return nil
+	}
+
+	ln, err := net.Listen("tcp", s.http.Addr)
+	if err != nil {
+		return fmt.Errorf("server listen error: %w", err)
+	}
+	s.ln = ln
+
+	go func() {
+		err := s.http.Serve(ln)
+		if err != nil && err != http.ErrServerClosed {
+			s.mu.Lock()
+			s.serveErr = fmt.Errorf("server serve error: %w", err)
+			s.mu.Unlock()
+		}
+		close(s.done)
+	}()
+
+	return nil
+}
+
+// Addr - The address the server listens on. Once started,
+// this is the actual address, e.g. when listening on port 0.
+func (s *HTTPserver) Addr() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.ln != nil {
+		return s.ln.Addr().String()
+	}
+
+	return s.http.Addr
+}
+
+// URL - The URL of the path on the server.
+func (s *HTTPserver) URL(path string) string {
+	return "http://" + s.Addr() + path
+}
+
+// Handle - Serve the handler on the path and return its URL.
+func (s *HTTPserver) Handle(path string, handler http.HandlerFunc) (string, error) {
+	if err := s.addRoute(path, &route{handler: handler}); err != nil {
+		return "", err
+	}
+
+	return s.URL(path), nil
+}
+
+// AddItem - Serve the media on the path and return its URL. The
+// media is either a local file path, a []byte, an io.ReadCloser,
+// a *Spool or a *urlstreamer.RangedURL.
+func (s *HTTPserver) AddItem(path string, media interface{}, mediaType string) (string, error) {
+	served := media
+	if f, ok := media.(string); ok {
+		if tf := newTimedFile(f); tf != nil {
+			served = tf
+		}
+	}
+
+	err := s.addRoute(path, &route{
+		handler: func(w http.ResponseWriter, req *http.Request) {
+			serveContent(w, req, mediaType, served, true)
+		},
+		media:   media,
+		isMedia: true,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return s.URL(path), nil
+}
+
+// AddSubtitles - Serve the subtitles on the path and return its URL.
+func (s *HTTPserver) AddSubtitles(path string, subtitles interface{}) (string, error) {
+	err := s.addRoute(path, &route{
+		handler: func(w http.ResponseWriter, req *http.Request) {
+			serveContent(w, req, "", subtitles, false)
+		},
+		media: subtitles,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return s.URL(path), nil
+}
+
+// RemoveItem - Stop serving the path. Media that can be
+// closed, like streams and spools, are closed.
+func (s *HTTPserver) RemoveItem(path string) {
+	s.mu.Lock()
+	rt, ok := s.routes[path]
+	delete(s.routes, path)
+	s.mu.Unlock()
+
+	if ok {
+		closeMedia(rt.media)
+	}
+}
+
+func (s *HTTPserver) addRoute(path string, rt *route) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.routes[path]; exists {
+		return fmt.Errorf("path already served: %s", path)
+	}
+
+	s.routes[path] = rt
+
+	return nil
+}
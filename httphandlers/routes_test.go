@@ -0,0 +1,87 @@
+package httphandlers
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDynamicRoutes(t *testing.T) {
+	s := NewServer("127.0.0.1:0")
+	if err := s.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	defer s.StopServeFiles()
+
+	mediaURL, err := s.AddItem("/media.mp4", []byte("go2tv"), "video/mp4")
+	if err != nil {
+		t.Fatalf("AddItem: %v", err)
+	}
+
+	if _, err := s.AddItem("/media.mp4", []byte("go2tv"), "video/mp4"); err == nil {
+		t.Errorf("AddItem: expected error for a path already served")
+	}
+
+	resp, err := http.Get(mediaURL)
+	if err != nil {
+		t.Fatalf("GET %s: %v", mediaURL, err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK || string(body) != "go2tv" {
+		t.Errorf("GET %s: got: %s %q, want: 200 OK \"go2tv\"", mediaURL, resp.Status, body)
+	}
+
+	s.RemoveItem("/media.mp4")
+
+	resp, err = http.Get(mediaURL)
+	if err != nil {
+		t.Fatalf("GET %s: %v", mediaURL, err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("GET %s after RemoveItem: got: %s, want: 404", mediaURL, resp.Status)
+	}
+}
+
+type closeRecorder struct {
+	io.Reader
+	closed bool
+}
+
+func (c *closeRecorder) Close() error {
+	c.closed = true
+	return nil
+}
+
+func TestShutdown(t *testing.T) {
+	s := NewServer("127.0.0.1:0")
+	if err := s.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+
+	media := &closeRecorder{Reader: strings.NewReader("go2tv")}
+	if _, err := s.AddItem("/media.mp4", media, "video/mp4"); err != nil {
+		t.Fatalf("AddItem: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	if err := s.Shutdown(ctx); err != nil {
+		t.Errorf("Shutdown: %v", err)
+	}
+
+	if !media.closed {
+		t.Errorf("Shutdown: media was not closed")
+	}
+
+	if s.HasPath("/media.mp4") {
+		t.Errorf("Shutdown: route was not removed")
+	}
+}
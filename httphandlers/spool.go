@@ -0,0 +1,317 @@
+package httphandlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// spoolReadAhead - How far the upstream read may get
+// ahead of the furthest reader before it pauses.
+const spoolReadAhead = 64 << 20
+
+var errSpoolClosed = errors.New("spool closed")
+
+// Spool - Tees an io.ReadCloser into a temp file, so that the media
+// can be requested more than once and from any offset that was already
+// downloaded. All the requests share the one upstream read. Readers
+// asking for data past the downloaded part wait for it to arrive.
+// The upstream read pauses once it's 64MB ahead of the furthest
+// reader, so that live or very long streams don't fill up the disk
+// while nobody is watching.
+type Spool struct {
+	src       io.ReadCloser
+	file      *os.File
+	modTime   time.Time
+	size      int64
+	furthest  int64
+	readAhead int64
+	done      bool
+	err       error
+	mu        sync.Mutex
+	cond      *sync.Cond
+}
+
+// NewSpool - Start spooling the src to a temp file. The
+// Spool must be closed to remove the temp file.
+func NewSpool(src io.ReadCloser) (*Spool, error) {
+	return newSpool(src, spoolReadAhead)
+}
+
+func newSpool(src io.ReadCloser, readAhead int64) (*Spool, error) {
+	f, err := os.CreateTemp("", "go2tv-spool-*")
+	if err != nil {
+		return nil, fmt.Errorf("NewSpool temp file error: %w", err)
+	}
+
+	s := &Spool{
+		src:       src,
+		file:      f,
+		modTime:   time.Now(),
+		readAhead: readAhead,
+	}
+	s.cond = sync.NewCond(&s.mu)
+
+	go s.fill()
+
+	return s, nil
+}
+
+func (s *Spool) fill() {
+	buf := make([]byte, 32*1024)
+	for {
+		if !s.waitForReaders() {
+			return
+		}
+
+		n, err := s.src.Read(buf)
+		if n > 0 {
+			// We're the only writer, so the
+			// offset doesn't need the lock.
+			if _, werr := s.file.WriteAt(buf[:n], s.written()); werr != nil {
+				err = werr
+			} else {
+				s.mu.Lock()
+				s.size += int64(n)
+				s.cond.Broadcast()
+				s.mu.Unlock()
+			}
+		}
+
+		if err != nil {
+			s.mu.Lock()
+			if err == io.EOF {
+				s.done = true
+			} else if s.err == nil {
+				s.err = err
+			}
+			s.cond.Broadcast()
+			s.mu.Unlock()
+			return
+		}
+	}
+}
+
+// waitForReaders - Block while the spool is too far ahead of
+// the furthest reader. Returns false once the spool is closed.
+func (s *Spool) waitForReaders() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for s.size-s.furthest >= s.readAhead && s.err == nil {
+		s.cond.Wait()
+	}
+
+	return s.err == nil
+}
+
+func (s *Spool) written() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.size
+}
+
+// waitFor - Block until the spool has data at off, or
+// there won't be any. Returns the bytes available from
+// off and whether the upstream read is complete.
+func (s *Spool) waitFor(off int64) (int64, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Let the upstream read move on, if it was waiting for us.
+	if off > s.furthest {
+		s.furthest = off
+		s.cond.Broadcast()
+	}
+
+	for off >= s.size && !s.done && s.err == nil {
+		s.cond.Wait()
+	}
+
+	if off < s.size {
+		return s.size - off, s.done, nil
+	}
+
+	if s.err != nil {
+		return 0, false, s.err
+	}
+
+	return 0, true, nil
+}
+
+// complete - Return the total size, once known.
+func (s *Spool) complete() (int64, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.size, s.done
+}
+
+// Close - Stop the upstream read and remove the temp file.
+func (s *Spool) Close() error {
+	s.mu.Lock()
+	if s.err == errSpoolClosed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.err = errSpoolClosed
+	s.done = false
+	s.cond.Broadcast()
+	s.mu.Unlock()
+
+	s.src.Close()
+	s.file.Close()
+
+	return os.Remove(s.file.Name())
+}
+
+// newReader - Return a reader starting at the beginning of the media.
+func (s *Spool) newReader() *spoolReader {
+	return &spoolReader{s: s}
+}
+
+// spoolReader - An io.ReadSeeker over the Spool. Seeking
+// relative to the end only works once the size is known.
+type spoolReader struct {
+	s   *Spool
+	off int64
+}
+
+func (r *spoolReader) Read(p []byte) (int, error) {
+	avail, _, err := r.s.waitFor(r.off)
+	if err != nil {
+		return 0, err
+	}
+
+	if avail == 0 {
+		return 0, io.EOF
+	}
+
+	if int64(len(p)) > avail {
+		p = p[:avail]
+	}
+
+	n, err := r.s.file.ReadAt(p, r.off)
+	r.off += int64(n)
+	if err == io.EOF && n > 0 {
+		err = nil
+	}
+
+	return n, err
+}
+
+func (r *spoolReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += r.off
+	case io.SeekEnd:
+		size, ok := r.s.complete()
+		if !ok {
+			return 0, errors.New("spoolReader: size not known yet")
+		}
+		offset += size
+	default:
+		return 0, errors.New("spoolReader: invalid whence")
+	}
+
+	if offset < 0 {
+		return 0, errors.New("spoolReader: negative position")
+	}
+
+	r.off = offset
+
+	return offset, nil
+}
+
+// serveSpool - Serve the spooled media. Once the upstream read is
+// complete, this is a regular http.ServeContent. Before that, the
+// total size is unknown, so ranged requests are answered with the
+// part of the range that was already downloaded and the clients
+// ask for the rest later.
+func serveSpool(w http.ResponseWriter, r *http.Request, s *Spool) {
+	name := strings.TrimLeft(r.URL.Path, "/")
+
+	if _, done := s.complete(); done {
+		http.ServeContent(w, r, name, s.modTime, s.newReader())
+		return
+	}
+
+	rangeHeader := r.Header.Get("Range")
+	if rangeHeader == "" {
+		w.WriteHeader(http.StatusOK)
+		if r.Method == http.MethodGet {
+			io.Copy(w, s.newReader())
+		}
+		return
+	}
+
+	start, end, err := parseOpenRange(rangeHeader)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusRequestedRangeNotSatisfiable)
+		return
+	}
+
+	avail, done, err := s.waitFor(start)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// The upstream read completed while we were
+	// waiting, so we know the total size now.
+	if done {
+		http.ServeContent(w, r, name, s.modTime, s.newReader())
+		return
+	}
+
+	last := start + avail - 1
+	if end >= 0 && end < last {
+		last = end
+	}
+
+	reader := s.newReader()
+	reader.off = start
+
+	w.Header().Set("Content-Range", "bytes "+strconv.FormatInt(start, 10)+"-"+strconv.FormatInt(last, 10)+"/*")
+	w.Header().Set("Content-Length", strconv.FormatInt(last-start+1, 10))
+	w.WriteHeader(http.StatusPartialContent)
+
+	if r.Method == http.MethodGet {
+		io.CopyN(w, reader, last-start+1)
+	}
+}
+
+// parseOpenRange - Parse a single "bytes=start-[end]" range.
+// Suffix ranges need the total size, so they're not supported
+// here. end is -1 for open ended ranges.
+func parseOpenRange(h string) (int64, int64, error) {
+	if !strings.HasPrefix(h, "bytes=") || strings.Contains(h, ",") {
+		return 0, 0, errors.New("unsupported range")
+	}
+
+	parts := strings.SplitN(strings.TrimPrefix(h, "bytes="), "-", 2)
+	if len(parts) != 2 || parts[0] == "" {
+		return 0, 0, errors.New("unsupported range")
+	}
+
+	start, err := strconv.ParseInt(strings.TrimSpace(parts[0]), 10, 64)
+	if err != nil || start < 0 {
+		return 0, 0, errors.New("invalid range")
+	}
+
+	end := int64(-1)
+	if e := strings.TrimSpace(parts[1]); e != "" {
+		end, err = strconv.ParseInt(e, 10, 64)
+		if err != nil || end < start {
+			return 0, 0, errors.New("invalid range")
+		}
+	}
+
+	return start, end, nil
+}
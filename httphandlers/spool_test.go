@@ -0,0 +1,138 @@
+package httphandlers
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSpool(t *testing.T) {
+	data := bytes.Repeat([]byte("go2tv"), 20000)
+
+	s, err := NewSpool(io.NopCloser(bytes.NewReader(data)))
+	if err != nil {
+		t.Fatalf("NewSpool: %v", err)
+	}
+	defer s.Close()
+
+	for i := 0; i < 2; i++ {
+		got, err := io.ReadAll(s.newReader())
+		if err != nil {
+			t.Fatalf("read #%d: %v", i, err)
+		}
+
+		if !bytes.Equal(got, data) {
+			t.Errorf("read #%d: got: %d bytes, want: %d bytes", i, len(got), len(data))
+		}
+	}
+
+	tt := []struct {
+		name       string
+		rangeValue string
+		wantStatus int
+		wantBody   []byte
+	}{
+		{
+			`Full request`,
+			"",
+			http.StatusOK,
+			data,
+		},
+		{
+			`Ranged request`,
+			"bytes=10-19",
+			http.StatusPartialContent,
+			data[10:20],
+		},
+	}
+
+	for _, tc := range tt {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		if tc.rangeValue != "" {
+			r.Header.Set("Range", tc.rangeValue)
+		}
+
+		serveContent(w, r, "video/mp4", s, true)
+
+		if w.Result().StatusCode != tc.wantStatus {
+			t.Errorf("%s: got: %s, want: %d.", tc.name, w.Result().Status, tc.wantStatus)
+		}
+
+		if !bytes.Equal(w.Body.Bytes(), tc.wantBody) {
+			t.Errorf("%s: got: %d bytes, want: %d bytes", tc.name, w.Body.Len(), len(tc.wantBody))
+		}
+	}
+}
+
+func TestParseOpenRange(t *testing.T) {
+	tt := []struct {
+		input     string
+		wantStart int64
+		wantEnd   int64
+		wantErr   bool
+	}{
+		{"bytes=0-", 0, -1, false},
+		{"bytes=100-199", 100, 199, false},
+		{"bytes=-500", 0, 0, true},
+		{"bytes=0-1,5-6", 0, 0, true},
+		{"bytes=20-10", 0, 0, true},
+	}
+
+	for _, tc := range tt {
+		start, end, err := parseOpenRange(tc.input)
+		if (err != nil) != tc.wantErr {
+			t.Errorf("%s: got error: %v, want error: %t", tc.input, err, tc.wantErr)
+			continue
+		}
+
+		if start != tc.wantStart || end != tc.wantEnd {
+			t.Errorf("%s: got: %d-%d, want: %d-%d", tc.input, start, end, tc.wantStart, tc.wantEnd)
+		}
+	}
+}
+
+type endlessReader struct{}
+
+func (endlessReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = 'x'
+	}
+	return len(p), nil
+}
+
+func TestSpoolReadAhead(t *testing.T) {
+	const readAhead = 64 * 1024
+
+	s, err := newSpool(io.NopCloser(endlessReader{}), readAhead)
+	if err != nil {
+		t.Fatalf("newSpool: %v", err)
+	}
+	defer s.Close()
+
+	// The upstream read can overshoot by one buffer at most.
+	waitSpooled := func(min, max int64) {
+		for deadline := time.Now().Add(5 * time.Second); s.written() < min && time.Now().Before(deadline); {
+			time.Sleep(10 * time.Millisecond)
+		}
+		time.Sleep(50 * time.Millisecond)
+
+		if got := s.written(); got < min || got > max {
+			t.Errorf("spooled: got: %d bytes, want: %d to %d bytes", got, min, max)
+		}
+	}
+
+	waitSpooled(readAhead, readAhead+32*1024)
+
+	r := s.newReader()
+	if _, err := io.CopyN(io.Discard, r, 100*1024); err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	// The next read lets the spool know how far we got.
+	r.Read(make([]byte, 1))
+
+	waitSpooled(100*1024+readAhead, 100*1024+readAhead+32*1024)
+}
@@ -0,0 +1,208 @@
+package httphandlers
+
+import (
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// AccessLogEntry - What a client requested and how it went.
+type AccessLogEntry struct {
+	Time          time.Time
+	ClientIP      string
+	Method        string
+	Path          string
+	Range         string
+	TimeSeekRange string
+	Status        int
+	Bytes         int64
+	Duration      time.Duration
+	// Aborted - The client went away before
+	// the response was fully sent.
+	Aborted bool
+}
+
+// String - Format the entry as an access log line.
+func (e AccessLogEntry) String() string {
+	outcome := "complete"
+	if e.Aborted {
+		outcome = "aborted"
+	}
+
+	return e.ClientIP + " " + e.Method + " " + e.Path +
+		" range=" + strconv.Quote(e.Range) +
+		" timeseekrange=" + strconv.Quote(e.TimeSeekRange) +
+		" status=" + strconv.Itoa(e.Status) +
+		" bytes=" + strconv.FormatInt(e.Bytes, 10) +
+		" duration=" + e.Duration.Round(time.Millisecond).String() +
+		" " + outcome
+}
+
+// Stats - The counters of the media served during the session.
+type Stats struct {
+	Requests int
+	// MediaRequests - Requests to media routes, as
+	// opposed to the callback and the subtitles.
+	MediaRequests int
+	// Reconnects - Media requests past the first one
+	// for the same media, e.g. on seeks and stalls.
+	Reconnects int
+	Aborted    int
+	BytesSent  int64
+	// TransferTime - The time spent serving media.
+	TransferTime time.Duration
+}
+
+// Throughput - The average media throughput, in bytes per second.
+func (s Stats) Throughput() float64 {
+	if s.TransferTime <= 0 {
+		return 0
+	}
+
+	return float64(s.BytesSent) / s.TransferTime.Seconds()
+}
+
+// SetAccessLog - Log every request to w. A nil w disables logging.
+func (s *HTTPserver) SetAccessLog(w io.Writer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if w == nil {
+		s.accessLog = nil
+		return
+	}
+
+	s.accessLog = log.New(w, "", log.LstdFlags)
+}
+
+// Stats - Return the counters of the session so far.
+func (s *HTTPserver) Stats() Stats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.stats
+}
+
+// record - Account for a served request.
+func (s *HTTPserver) record(e AccessLogEntry, isMedia bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.stats.Requests++
+	if e.Aborted {
+		s.stats.Aborted++
+	}
+
+	if isMedia {
+		s.stats.MediaRequests++
+		s.stats.BytesSent += e.Bytes
+		s.stats.TransferTime += e.Duration
+
+		if s.mediaHits[e.Path]++; s.mediaHits[e.Path] > 1 {
+			s.stats.Reconnects++
+		}
+	}
+
+	if s.accessLog != nil {
+		s.accessLog.Println(e.String())
+	}
+}
+
+// statsWriter - Records the status and
+// the bytes written to the client.
+type statsWriter struct {
+	http.ResponseWriter
+	status   int
+	bytes    int64
+	writeErr error
+}
+
+func (w *statsWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statsWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += int64(n)
+	if err != nil && w.writeErr == nil {
+		w.writeErr = err
+	}
+
+	return n, err
+}
+
+// ReadFrom - Keep the sendfile path of http.ServeContent.
+func (w *statsWriter) ReadFrom(r io.Reader) (int64, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+
+	var n int64
+	var err error
+	if rf, ok := w.ResponseWriter.(io.ReaderFrom); ok {
+		n, err = rf.ReadFrom(r)
+	} else {
+		// Hide our ReadFrom, or io.Copy would call it back.
+		n, err = io.Copy(struct{ io.Writer }{w.ResponseWriter}, r)
+	}
+	w.bytes += n
+
+	// Either way, the response is incomplete.
+	if err != nil && w.writeErr == nil {
+		w.writeErr = err
+	}
+
+	return n, err
+}
+
+// Flush - Send the buffered data to the client.
+func (w *statsWriter) Flush() {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// newAccessLogEntry - Build the entry of a served request.
+func newAccessLogEntry(r *http.Request, w *statsWriter, start time.Time) AccessLogEntry {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+
+	status := w.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	aborted := w.writeErr != nil || r.Context().Err() != nil
+	if cl, err := strconv.ParseInt(w.Header().Get("Content-Length"), 10, 64); err == nil &&
+		r.Method != http.MethodHead && w.bytes < cl {
+		aborted = true
+	}
+
+	return AccessLogEntry{
+		Time:          start,
+		ClientIP:      ip,
+		Method:        r.Method,
+		Path:          r.URL.Path,
+		Range:         r.Header.Get("Range"),
+		TimeSeekRange: r.Header.Get("TimeSeekRange.dlna.org"),
+		Status:        status,
+		Bytes:         w.bytes,
+		Duration:      time.Since(start),
+		Aborted:       aborted,
+	}
+}
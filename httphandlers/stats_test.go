@@ -0,0 +1,73 @@
+package httphandlers
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestStats(t *testing.T) {
+	s := NewServer("127.0.0.1:0")
+
+	var logBuf bytes.Buffer
+	s.SetAccessLog(&logBuf)
+
+	if _, err := s.AddItem("/media.mp4", []byte("go2tv"), "video/mp4"); err != nil {
+		t.Fatalf("AddItem: %v", err)
+	}
+
+	for _, rangeValue := range []string{"", "bytes=2-"} {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/media.mp4", nil)
+		if rangeValue != "" {
+			r.Header.Set("Range", rangeValue)
+		}
+		s.ServeHTTP(w, r)
+	}
+
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/missing", nil))
+
+	st := s.Stats()
+	if st.Requests != 3 || st.MediaRequests != 2 || st.Reconnects != 1 || st.BytesSent != 8 {
+		t.Errorf("Stats: got: %+v, want: 3 requests, 2 media requests, 1 reconnect, 8 bytes", st)
+	}
+
+	lines := strings.Split(strings.TrimSpace(logBuf.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("SetAccessLog: got: %d lines, want: 3", len(lines))
+	}
+
+	if !strings.Contains(lines[1], `range="bytes=2-" timeseekrange="" status=206 bytes=3`) || !strings.HasSuffix(lines[1], "complete") {
+		t.Errorf("SetAccessLog: got: %s", lines[1])
+	}
+
+	if !strings.Contains(lines[2], "status=404") {
+		t.Errorf("SetAccessLog: got: %s, want: status=404", lines[2])
+	}
+}
+
+func TestStatsWriterInterfaces(t *testing.T) {
+	rec := httptest.NewRecorder()
+	var w http.ResponseWriter = &statsWriter{ResponseWriter: rec}
+
+	if _, ok := w.(http.Flusher); !ok {
+		t.Errorf("statsWriter: does not implement http.Flusher")
+	}
+
+	rf, ok := w.(io.ReaderFrom)
+	if !ok {
+		t.Fatalf("statsWriter: does not implement io.ReaderFrom")
+	}
+
+	if _, err := rf.ReadFrom(strings.NewReader("go2tv")); err != nil {
+		t.Fatalf("ReadFrom: %v", err)
+	}
+
+	if sw := w.(*statsWriter); sw.bytes != 5 || rec.Body.String() != "go2tv" {
+		t.Errorf("ReadFrom: got: %d bytes %q, want: 5 bytes \"go2tv\"", sw.bytes, rec.Body.String())
+	}
+}
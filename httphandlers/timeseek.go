@@ -0,0 +1,121 @@
+package httphandlers
+
+import (
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/chyroc/go2tv/utils"
+)
+
+// timedFile - A local media file of known duration, which
+// makes it seekable by time as well as by byte range.
+type timedFile struct {
+	path     string
+	duration time.Duration
+}
+
+// newTimedFile - Probe the duration of the media file once, instead
+// of on every request. Returns nil if the duration is unknown.
+func newTimedFile(path string) *timedFile {
+	duration, err := utils.MediaDuration(path)
+	if err != nil {
+		return nil
+	}
+
+	return &timedFile{path: path, duration: duration}
+}
+
+// serveFile - Serve a local file. A non-zero duration enables
+// the TimeSeekRange.dlna.org requests.
+func serveFile(w http.ResponseWriter, r *http.Request, mediaType, path string, duration time.Duration) {
+	seek := "01"
+	if duration > 0 {
+		seek = "11"
+	}
+
+	if r.Header.Get("getcontentFeatures.dlna.org") == "1" {
+		contentFeatures, err := utils.BuildContentFeatures(mediaType, seek, false)
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+
+		w.Header()["contentFeatures.dlna.org"] = []string{contentFeatures}
+	}
+
+	filePath, err := os.Open(path)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	defer filePath.Close()
+
+	fileStat, err := filePath.Stat()
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	if r.Header.Get("TimeSeekRange.dlna.org") != "" {
+		if duration <= 0 {
+			http.Error(w, "time based seek not supported", http.StatusNotAcceptable)
+			return
+		}
+
+		serveTimeSeek(w, r, filePath, fileStat.Size(), duration)
+		return
+	}
+
+	name := strings.TrimLeft(r.URL.Path, "/")
+	http.ServeContent(w, r, name, fileStat.ModTime(), filePath)
+}
+
+// serveTimeSeek - Answer a TimeSeekRange.dlna.org request by mapping
+// the npt range to a byte range. Without an index of the media file,
+// we assume a constant bitrate, which is close enough for the media
+// renderers to resync on the next keyframe.
+func serveTimeSeek(w http.ResponseWriter, r *http.Request, f io.ReadSeeker, size int64, duration time.Duration) {
+	start, end, err := utils.ParseNPTRange(r.Header.Get("TimeSeekRange.dlna.org"))
+	if err != nil || start >= duration {
+		http.Error(w, "invalid TimeSeekRange", http.StatusRequestedRangeNotSatisfiable)
+		return
+	}
+
+	if end < 0 || end > duration {
+		end = duration
+	}
+
+	first := int64(float64(size) * (float64(start) / float64(duration)))
+	last := size - 1
+	if end < duration {
+		last = int64(float64(size)*(float64(end)/float64(duration))) - 1
+	}
+	if last < first {
+		last = first
+	}
+
+	sizeStr := strconv.FormatInt(size, 10)
+	byteRange := strconv.FormatInt(first, 10) + "-" + strconv.FormatInt(last, 10) + "/" + sizeStr
+
+	respHeader := w.Header()
+	respHeader["TimeSeekRange.dlna.org"] = []string{"npt=" + utils.FormatNPTTime(start) + "-" +
+		utils.FormatNPTTime(end) + "/" + utils.FormatNPTTime(duration) + " bytes=" + byteRange}
+	// Unlike byte range requests, time seek requests are answered
+	// with a 200 OK, the range only goes in the TimeSeekRange header.
+	respHeader.Set("Content-Length", strconv.FormatInt(last-first+1, 10))
+	w.WriteHeader(http.StatusOK)
+
+	if r.Method != http.MethodGet {
+		return
+	}
+
+	if _, err := f.Seek(first, io.SeekStart); err != nil {
+		return
+	}
+
+	io.CopyN(w, f, last-first+1)
+}
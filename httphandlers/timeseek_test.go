@@ -0,0 +1,72 @@
+package httphandlers
+
+import (
+	"encoding/binary"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestServeTimeSeek(t *testing.T) {
+	// A moov box with a version 0 mvhd of 100s, padded
+	// with an mdat box to 1000 bytes.
+	mvhd := make([]byte, 108)
+	binary.BigEndian.PutUint32(mvhd, uint32(len(mvhd)))
+	copy(mvhd[4:], "mvhd")
+	binary.BigEndian.PutUint32(mvhd[20:], 1)
+	binary.BigEndian.PutUint32(mvhd[24:], 100)
+
+	moov := make([]byte, 8, 8+len(mvhd))
+	binary.BigEndian.PutUint32(moov, uint32(8+len(mvhd)))
+	copy(moov[4:], "moov")
+	moov = append(moov, mvhd...)
+
+	mdat := make([]byte, 1000-len(moov))
+	binary.BigEndian.PutUint32(mdat, uint32(len(mdat)))
+	copy(mdat[4:], "mdat")
+
+	f := filepath.Join(t.TempDir(), "test.mp4")
+	if err := os.WriteFile(f, append(moov, mdat...), 0o600); err != nil {
+		t.Fatalf("write error: %v", err)
+	}
+
+	s := NewServer("127.0.0.1:0")
+	if _, err := s.AddItem("/test.mp4", f, "video/mp4"); err != nil {
+		t.Fatalf("AddItem error: %v", err)
+	}
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/test.mp4", nil)
+	r.Header.Set("getcontentFeatures.dlna.org", "1")
+	r.Header.Set("TimeSeekRange.dlna.org", "npt=50-")
+
+	s.ServeHTTP(w, r)
+
+	resp := w.Result()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("TimeSeekRange: got: %s, want: %d.", resp.Status, http.StatusOK)
+	}
+
+	if got := resp.Header.Get("Content-Range"); got != "" {
+		t.Errorf("TimeSeekRange: got: Content-Range %s, want: none", got)
+	}
+
+	if got := resp.Header.Get("Content-Length"); got != "500" {
+		t.Errorf("TimeSeekRange: got: Content-Length %s, want: 500", got)
+	}
+
+	if got := strings.Join(resp.Header["TimeSeekRange.dlna.org"], ""); got != "npt=50.000-100.000/100.000 bytes=500-999/1000" {
+		t.Errorf("TimeSeekRange: got: %s, want: npt=50.000-100.000/100.000 bytes=500-999/1000", got)
+	}
+
+	if got := strings.Join(resp.Header["contentFeatures.dlna.org"], ""); !strings.Contains(got, "DLNA.ORG_OP=11") {
+		t.Errorf("TimeSeekRange: got: %s, want: DLNA.ORG_OP=11", got)
+	}
+
+	if w.Body.Len() != 500 {
+		t.Errorf("TimeSeekRange: got: %d bytes, want: 500 bytes", w.Body.Len())
+	}
+}
@@ -3,12 +3,14 @@ package interactive
 import (
 	"errors"
 	"net/url"
+	"path"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/chyroc/go2tv/soapcalls"
+	"github.com/chyroc/go2tv/utils"
 	"github.com/gdamore/tcell/v2"
 	"github.com/gdamore/tcell/v2/encoding"
 	"github.com/mattn/go-runewidth"
@@ -21,8 +23,15 @@ type NewScreen struct {
 	TV         *soapcalls.TVPayload
 	mediaTitle string
 	lastAction string
+	// notice - A transient message, e.g. a failed seek, shown
+	// below the key bindings until noticeExpires.
+	notice        string
+	noticeExpires time.Time
 }
 
+// noticeTimeout - How long a notice stays on screen.
+const noticeTimeout = 3 * time.Second
+
 var flipflop bool = true
 
 func (p *NewScreen) emitStr(x, y int, style tcell.Style, str string) {
@@ -46,8 +55,17 @@ func (p *NewScreen) EmitMsg(inputtext string) {
 	p.updateLastAction(inputtext)
 	s := p.Current
 
+	// The current media item changes as the queue advances.
+	if p.TV != nil {
+		p.updateMediaTitle(p.TV.CurrentMediaURL())
+	}
+
 	p.mu.RLock()
 	mediaTitle := p.mediaTitle
+	notice := p.notice
+	if time.Now().After(p.noticeExpires) {
+		notice = ""
+	}
 	p.mu.RUnlock()
 
 	titleLen := len("Title: " + mediaTitle)
@@ -61,51 +79,104 @@ func (p *NewScreen) EmitMsg(inputtext string) {
 
 	s.Clear()
 
-	p.emitStr(w/2-titleLen/2, h/2-2, tcell.StyleDefault, "Title: "+mediaTitle)
+	p.emitStr(w/2-titleLen/2, h/2-4, tcell.StyleDefault, "Title: "+mediaTitle)
 	if inputtext == "Waiting for status..." {
-		p.emitStr(w/2-len(inputtext)/2, h/2, blinkStyle, inputtext)
+		p.emitStr(w/2-len(inputtext)/2, h/2-2, blinkStyle, inputtext)
 	} else {
-		p.emitStr(w/2-len(inputtext)/2, h/2, boldStyle, inputtext)
+		p.emitStr(w/2-len(inputtext)/2, h/2-2, boldStyle, inputtext)
 	}
 	p.emitStr(1, 1, tcell.StyleDefault, "Press ESC to stop.")
 
-	isMute := "0"
-	var err error
-
-	if p.TV != nil {
-		isMute, err = p.TV.GetMuteSoapCall()
+	if inputtext == "Playing" || inputtext == "Paused" {
+		if progress := p.progressBar(w); progress != "" {
+			p.emitStr(w/2-len(progress)/2, h/2, tcell.StyleDefault, progress)
+		}
 	}
 
-	if err != nil || isMute == "0" {
-		p.emitStr(w/2-len("")/2, h/2+2, tcell.StyleDefault, "")
-	} else {
+	// The mute state is kept up to date by the
+	// RenderingControl events, no need to ask the TV.
+	if p.TV != nil && p.TV.GetRenderingControlState().Mute {
 		p.emitStr(w/2-len("MUTED")/2, h/2+2, blinkStyle, "MUTED")
 	}
 	p.emitStr(w/2-len(`"p" (Play/Pause)`)/2, h/2+4, tcell.StyleDefault, `"p" (Play/Pause)`)
 	p.emitStr(w/2-len(`"m" (Mute/Unmute)`)/2, h/2+6, tcell.StyleDefault, `"m" (Mute/Unmute)`)
 	p.emitStr(w/2-len(`"Page Up" "Page Down" (Volume Up/Down)`)/2, h/2+8, tcell.StyleDefault, `"Page Up" "Page Down" (Volume Up/Down)`)
+	p.emitStr(w/2-len(`"Left" "Right" (-/+10s) "Down" "Up" (-/+60s)`)/2, h/2+10, tcell.StyleDefault, `"Left" "Right" (-/+10s) "Down" "Up" (-/+60s)`)
+	if notice != "" {
+		p.emitStr(w/2-len(notice)/2, h/2+12, boldStyle, notice)
+	}
 	s.Show()
 }
 
+// Refresh - Redraw the screen keeping the current status.
+func (p *NewScreen) Refresh() {
+	p.EmitMsg(p.getLastAction())
+}
+
+// progressBar - Build the elapsed/total time progress bar
+// that fits in the screen width. Returns an empty string
+// if the media renderer can't report the playback position.
+func (p *NewScreen) progressBar(screenWidth int) string {
+	if p.TV == nil {
+		return ""
+	}
+
+	pos, err := p.TV.GetPositionInfoSoapCall()
+	if err != nil {
+		return ""
+	}
+
+	elapsed, err := utils.ClockTimeToSeconds(pos.RelTime)
+	if err != nil {
+		return ""
+	}
+
+	elapsedStr, _ := utils.SecondsToClockTime(elapsed)
+
+	total, err := utils.ClockTimeToSeconds(pos.TrackDuration)
+	if err != nil || total == 0 {
+		// Live streams and some media renderers don't
+		// report the duration, so we only show the elapsed time.
+		return elapsedStr
+	}
+
+	totalStr, _ := utils.SecondsToClockTime(total)
+
+	if elapsed > total {
+		elapsed = total
+	}
+
+	barWidth := screenWidth - len(elapsedStr) - len(totalStr) - 10
+	if barWidth > 50 {
+		barWidth = 50
+	}
+
+	if barWidth <= 0 {
+		return elapsedStr + " / " + totalStr
+	}
+
+	filled := barWidth * elapsed / total
+
+	return elapsedStr + " [" + strings.Repeat("=", filled) + strings.Repeat(" ", barWidth-filled) + "] " + totalStr
+}
+
 // InterInit - Start the interactive terminal
 func (p *NewScreen) InterInit(tv *soapcalls.TVPayload) error {
 	p.TV = tv
 
-	muteChecker := time.NewTicker(1 * time.Second)
+	// Only the playback position needs to be polled,
+	// and it only moves while playing.
+	progressTicker := time.NewTicker(1 * time.Second)
 
 	go func() {
-		for range muteChecker.C {
-			p.EmitMsg(p.getLastAction())
+		for range progressTicker.C {
+			if p.getLastAction() == "Playing" {
+				p.Refresh()
+			}
 		}
 	}()
 
-	p.mu.Lock()
-	p.mediaTitle = tv.MediaURL
-	mediaTitlefromURL, err := url.Parse(tv.MediaURL)
-	if err == nil {
-		p.mediaTitle = strings.TrimLeft(mediaTitlefromURL.Path, "/")
-	}
-	p.mu.Unlock()
+	p.updateMediaTitle(tv.CurrentMediaURL())
 
 	encoding.Register()
 	s := p.Current
@@ -167,6 +238,17 @@ func (p *NewScreen) HandleKeyEvent(ev *tcell.EventKey) bool {
 		}
 	}
 
+	switch ev.Key() {
+	case tcell.KeyLeft:
+		p.seek(-10)
+	case tcell.KeyRight:
+		p.seek(10)
+	case tcell.KeyDown:
+		p.seek(-60)
+	case tcell.KeyUp:
+		p.seek(60)
+	}
+
 	switch ev.Rune() {
 	case 'p':
 		if flipflop {
@@ -195,6 +277,43 @@ func (p *NewScreen) HandleKeyEvent(ev *tcell.EventKey) bool {
 	return false
 }
 
+// seek - Jump forwards or backwards relative
+// to the current playback position.
+func (p *NewScreen) seek(offset int) {
+	tv := p.TV
+
+	pos, err := tv.GetPositionInfoSoapCall()
+	if err != nil {
+		return
+	}
+
+	current, err := utils.ClockTimeToSeconds(pos.RelTime)
+	if err != nil {
+		return
+	}
+
+	target := current + offset
+	if target < 0 {
+		target = 0
+	}
+
+	// Don't seek past the end of the track, if the
+	// media renderer is aware of its duration.
+	duration, err := utils.ClockTimeToSeconds(pos.TrackDuration)
+	if err == nil && duration > 0 && target > duration {
+		target = duration
+	}
+
+	t, err := utils.SecondsToClockTime(target)
+	if err != nil {
+		return
+	}
+
+	if err := tv.SeekSoapCall("REL_TIME", t); err != nil {
+		p.showNotice("Seek failed: " + err.Error())
+	}
+}
+
 // Fini Method to implement the screen interface
 func (p *NewScreen) Fini() {
 	p.Current.Fini()
@@ -222,3 +341,26 @@ func (p *NewScreen) updateLastAction(s string) {
 	defer p.mu.Unlock()
 	p.lastAction = s
 }
+
+// showNotice - Show a transient message while
+// keeping the current status on screen.
+func (p *NewScreen) showNotice(s string) {
+	p.mu.Lock()
+	p.notice = s
+	p.noticeExpires = time.Now().Add(noticeTimeout)
+	p.mu.Unlock()
+
+	p.Refresh()
+}
+
+func (p *NewScreen) updateMediaTitle(mediaURL string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	// The media paths are prefixed with the session
+	// token, which has no place on the screen.
+	p.mediaTitle = path.Base(mediaURL)
+	mediaTitlefromURL, err := url.Parse(mediaURL)
+	if err == nil {
+		p.mediaTitle = path.Base(mediaTitlefromURL.Path)
+	}
+}
@@ -0,0 +1,73 @@
+package interactive
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/chyroc/go2tv/soapcalls"
+)
+
+func TestProgressBar(t *testing.T) {
+	tt := []struct {
+		name          string
+		relTime       string
+		trackDuration string
+		width         int
+		want          string
+	}{
+		{
+			`Half way`,
+			"0:00:30",
+			"0:01:00",
+			80,
+			"0:00:30 [" + strings.Repeat("=", 25) + strings.Repeat(" ", 25) + "] 0:01:00",
+		},
+		{
+			`Past the end`,
+			"0:02:00",
+			"0:01:00",
+			80,
+			"0:02:00 [" + strings.Repeat("=", 50) + "] 0:01:00",
+		},
+		{
+			`Narrow screen`,
+			"0:00:30",
+			"0:01:00",
+			20,
+			"0:00:30 / 0:01:00",
+		},
+		{
+			`Unknown duration`,
+			"0:00:30",
+			"NOT_IMPLEMENTED",
+			80,
+			"0:00:30",
+		},
+		{
+			`Unknown position`,
+			"NOT_IMPLEMENTED",
+			"0:01:00",
+			80,
+			"",
+		},
+	}
+
+	for _, tc := range tt {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			io.WriteString(w, `<?xml version="1.0"?><s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/"><s:Body>`+
+				`<u:GetPositionInfoResponse xmlns:u="urn:schemas-upnp-org:service:AVTransport:1">`+
+				`<TrackDuration>`+tc.trackDuration+`</TrackDuration><RelTime>`+tc.relTime+`</RelTime>`+
+				`</u:GetPositionInfoResponse></s:Body></s:Envelope>`)
+		}))
+
+		p := &NewScreen{TV: &soapcalls.TVPayload{ControlURL: srv.URL}}
+		if got := p.progressBar(tc.width); got != tc.want {
+			t.Errorf("%s: got: %q, want: %q.", tc.name, got, tc.want)
+		}
+
+		srv.Close()
+	}
+}
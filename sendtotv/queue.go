@@ -0,0 +1,77 @@
+package sendtotv
+
+import (
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/chyroc/go2tv/httphandlers"
+	"github.com/chyroc/go2tv/soapcalls"
+	"github.com/chyroc/go2tv/utils"
+)
+
+// mediaQueue - Implements the soapcalls.MediaQueue interface. Each
+// item is registered with the HTTP server only when it's requested,
+// so URL streams are not opened before they are needed.
+type mediaQueue struct {
+	mu            sync.Mutex
+	items         []*Media
+	pos           int
+	server        *httphandlers.HTTPserver
+	whereToListen string
+	// pathPrefix - Prepended to the item paths, see
+	// httphandlers.AccessPolicy.
+	pathPrefix string
+}
+
+// Next - Register the next playable item with the HTTP server
+// and return it. Items that can't be opened are skipped.
+func (q *mediaQueue) Next() (*soapcalls.QueueItem, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for q.pos < len(q.items) {
+		m := q.items[q.pos]
+		q.pos++
+
+		media, err := m.source()
+		if err != nil {
+			continue
+		}
+
+		// Two items with the same name would end up
+		// on the same path, so we tell them apart.
+		path := q.pathPrefix + "/" + utils.ConvertFilename(m.Name)
+		if q.server.HasPath(path) {
+			path = q.pathPrefix + "/" + strconv.Itoa(q.pos) + "-" + utils.ConvertFilename(m.Name)
+		}
+
+		item := &soapcalls.QueueItem{
+			MediaURL:  "http://" + q.whereToListen + path,
+			MediaType: m.mediaType(),
+		}
+
+		if err := q.server.AddMedia(item, media); err != nil {
+			if c, ok := media.(io.Closer); ok {
+				c.Close()
+			}
+			continue
+		}
+
+		return item, true
+	}
+
+	return nil, false
+}
+
+// Release - Stop serving the item, which closes its
+// URL stream and removes its spool file, if any.
+func (q *mediaQueue) Release(item *soapcalls.QueueItem) {
+	base := "http://" + q.whereToListen
+	for _, u := range []string{item.MediaURL, item.SubtitlesURL} {
+		if strings.HasPrefix(u, base+"/") {
+			q.server.RemoveItem(strings.TrimPrefix(u, base))
+		}
+	}
+}
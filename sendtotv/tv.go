@@ -1,22 +1,198 @@
 package sendtotv
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"io"
+	"os"
+	"strings"
 	"time"
 
+	"github.com/chyroc/go2tv/devices"
 	"github.com/chyroc/go2tv/httphandlers"
 	"github.com/chyroc/go2tv/interactive"
 	"github.com/chyroc/go2tv/soapcalls"
+	"github.com/chyroc/go2tv/urlstreamer"
 	"github.com/chyroc/go2tv/utils"
 )
 
+// shutdownTimeout - How long the HTTP server waits for
+// the transfers in flight once the casting is over.
+const shutdownTimeout = 5 * time.Second
+
+// Media - A media item to cast. Body takes precedence over
+// Path, which takes precedence over URL.
 type Media struct {
 	Name string
 	Body io.ReadCloser
+	// Path - Local path to the media file.
+	Path string
+	// URL - HTTP URL to the media file. The stream is only
+	// opened once the item is about to play. It's seekable if
+	// the server supports byte ranges.
+	URL string
+	// Spool - Spool Body and URL streams to a temp file, so that
+	// media renderers can request them more than once and seek
+	// within the part that was already downloaded. The temp file
+	// takes as much disk space as the media renderer has played,
+	// plus up to 64MB read ahead, and is removed once the item is
+	// done playing.
+	Spool bool
+}
+
+// source - Return the media in a form the HTTP server can serve.
+func (m *Media) source() (interface{}, error) {
+	var body io.ReadCloser
+	switch {
+	case m.Body != nil:
+		body = m.Body
+	case m.Path != "":
+		return m.Path, nil
+	case m.URL != "":
+		// Servers supporting byte ranges get the requests of
+		// the media renderer forwarded, which makes them seekable.
+		if !m.Spool {
+			if ranged, err := urlstreamer.NewRangedURL(context.Background(), m.URL); err == nil {
+				return ranged, nil
+			}
+		}
+
+		var err error
+		body, err = urlstreamer.StreamURL(context.Background(), m.URL)
+		if err != nil {
+			return nil, fmt.Errorf("media source error: %w", err)
+		}
+	default:
+		return nil, errors.New("media source error: no Body, Path or URL")
+	}
+
+	if !m.Spool {
+		return body, nil
+	}
+
+	spool, err := httphandlers.NewSpool(body)
+	if err != nil {
+		body.Close()
+		return nil, fmt.Errorf("media source error: %w", err)
+	}
+
+	return spool, nil
+}
+
+// mediaType - Sniff the MIME type of local files. Streams are
+// left alone as sniffing would consume them, so an empty string
+// is returned for those and for unknown file types.
+func (m *Media) mediaType() string {
+	if m.Body != nil || m.Path == "" {
+		return ""
+	}
+
+	mediaType, err := utils.GetMimeDetailsFromFile(m.Path)
+	if err != nil || mediaType == "/" {
+		return ""
+	}
+
+	return mediaType
+}
+
+// Target - The media renderer to cast to.
+type Target struct {
+	// DMRURL - The media renderer description URL, or a
+	// remembered device as accepted by ResolveTarget. URLs
+	// are used as-is, without looking up the device store.
+	DMRURL string
+	// ListenIP - The local address to serve the media on,
+	// usually the IP of the interface the media renderer was
+	// found on. If empty, the OS route to the media renderer
+	// decides.
+	ListenIP string
+	// AccessLog - If set, the requests of the media renderer
+	// and the session stats are appended to this file.
+	AccessLog string
+}
+
+// ResolveTarget - Turn a DMR URL, or the alias, UDN or friendly
+// name of a device remembered in the default device store, into a
+// Target. An empty name stands for the default device. Remembered
+// devices are re-discovered if they moved. Either way, the device
+// is remembered and recorded as the last used one, if the store
+// can be written to.
+func ResolveTarget(name string) (Target, error) {
+	store, err := devices.OpenStore("")
+	if err != nil {
+		// Casting to a URL doesn't need the store.
+		if isURL(name) {
+			return Target{DMRURL: name}, nil
+		}
+		return Target{}, fmt.Errorf("resolveTarget error: %w", err)
+	}
+
+	var dev *devices.Device
+	if isURL(name) {
+		dev, err = store.ResolveLocation(context.Background(), nil, name)
+	} else {
+		dev, err = store.Resolve(context.Background(), nil, name)
+	}
+	if err != nil {
+		return Target{}, fmt.Errorf("resolveTarget error: %w", err)
+	}
+
+	// Same as for the discovered devices, a store that
+	// can't be written to shouldn't get in the way of casting.
+	store.SetLastUsed(dev.UDN)
+	store.Save()
+
+	return Target{DMRURL: dev.Location, ListenIP: dev.Interface.IP}, nil
+}
+
+func isURL(s string) bool {
+	return strings.HasPrefix(s, "http://") || strings.HasPrefix(s, "https://")
 }
 
 func SendReadCloser(media, subTitle *Media, dmrURL string) error {
-	mediaBody := media.Body
+	return send(media, subTitle, nil, Target{DMRURL: dmrURL})
+}
+
+// SendToTarget - Cast the media, followed by the queued items,
+// to the target. subTitle may be nil.
+func SendToTarget(t Target, media, subTitle *Media, queued []*Media) error {
+	return send(media, subTitle, queued, t)
+}
+
+// SendQueue - Cast the media items one after the other. The next
+// item is preloaded on the media renderer while the current one
+// plays, so media renderers that support SetNextAVTransportURI
+// switch over without a gap.
+func SendQueue(items []*Media, dmrURL string) error {
+	if len(items) == 0 {
+		return errors.New("sendQueue: no media items")
+	}
+
+	return send(items[0], nil, items[1:], Target{DMRURL: dmrURL})
+}
+
+func send(media, subTitle *Media, queued []*Media, t Target) error {
+	// DMR URLs are used as-is, call ResolveTarget
+	// to have them looked up in the device store.
+	if !isURL(t.DMRURL) {
+		resolved, err := ResolveTarget(t.DMRURL)
+		if err != nil {
+			return err
+		}
+		// Only the address is resolved, the
+		// other options are the caller's.
+		t.DMRURL = resolved.DMRURL
+		if t.ListenIP == "" {
+			t.ListenIP = resolved.ListenIP
+		}
+	}
+	dmrURL := t.DMRURL
+
+	mediaBody, err := media.source()
+	if err != nil {
+		return err
+	}
 	mediaName := media.Name
 	subTitleName := ""
 	var subTitleBody io.ReadCloser
@@ -24,14 +200,33 @@ func SendReadCloser(media, subTitle *Media, dmrURL string) error {
 		subTitleName = subTitle.Name
 		subTitleBody = subTitle.Body
 	}
-	mediaType := ""
+	mediaType := media.mediaType()
+
+	// The media are ours to close until the HTTP server takes over.
+	served := false
+	defer func() {
+		if served {
+			return
+		}
+		if c, ok := mediaBody.(io.Closer); ok {
+			c.Close()
+		}
+		if subTitleBody != nil {
+			subTitleBody.Close()
+		}
+	}()
 
 	upnpServicesURLs, err := soapcalls.DMRextractor(dmrURL)
 	if err != nil {
 		return err
 	}
 
-	whereToListen, err := utils.URLtoListenIPandPort(dmrURL)
+	var whereToListen string
+	if t.ListenIP != "" {
+		whereToListen, err = utils.ListenIPandPort(t.ListenIP)
+	} else {
+		whereToListen, err = utils.URLtoListenIPandPort(dmrURL)
+	}
 	if err != nil {
 		return err
 	}
@@ -46,32 +241,80 @@ func SendReadCloser(media, subTitle *Media, dmrURL string) error {
 		return err
 	}
 
+	// Only the media renderer gets to fetch the media,
+	// and only with the token of this session.
+	policy, err := httphandlers.NewAccessPolicy(dmrURL)
+	if err != nil {
+		return err
+	}
+	baseURL := "http://" + whereToListen + policy.PathPrefix()
+
 	tvdata := &soapcalls.TVPayload{
-		ControlURL:          upnpServicesURLs.AvtransportControlURL,
-		EventURL:            upnpServicesURLs.AvtransportEventSubURL,
-		RenderingControlURL: upnpServicesURLs.RenderingControlURL,
-		CallbackURL:         "http://" + whereToListen + "/" + callbackPath,
-		MediaURL:            "http://" + whereToListen + "/" + utils.ConvertFilename(mediaName),
-		SubtitlesURL:        "http://" + whereToListen + "/" + utils.ConvertFilename(subTitleName),
-		MediaType:           mediaType,
-		CurrentTimers:       make(map[string]*time.Timer),
+		ControlURL:               upnpServicesURLs.AvtransportControlURL,
+		EventURL:                 upnpServicesURLs.AvtransportEventSubURL,
+		RenderingControlURL:      upnpServicesURLs.RenderingControlURL,
+		RenderingControlEventURL: upnpServicesURLs.RenderingControlEventSubURL,
+		ConnectionManagerURL:     upnpServicesURLs.ConnectionManagerURL,
+		CallbackURL:              baseURL + "/" + callbackPath,
+		MediaURL:                 baseURL + "/" + utils.ConvertFilename(mediaName),
+		SubtitlesURL:             baseURL + "/" + utils.ConvertFilename(subTitleName),
+		MediaType:                mediaType,
+		CurrentTimers:            make(map[string]*time.Timer),
 	}
 
 	s := httphandlers.NewServer(whereToListen)
+	s.SetAccessPolicy(policy)
+
+	if t.AccessLog != "" {
+		logFile, err := os.OpenFile(t.AccessLog, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+		if err != nil {
+			return fmt.Errorf("access log error: %w", err)
+		}
+		defer func() {
+			st := s.Stats()
+			fmt.Fprintf(logFile, "session stats: requests=%d media_requests=%d reconnects=%d aborted=%d bytes=%d throughput=%.0fB/s\n",
+				st.Requests, st.MediaRequests, st.Reconnects, st.Aborted, st.BytesSent, st.Throughput())
+			logFile.Close()
+		}()
+		s.SetAccessLog(logFile)
+	}
 	serverStarted := make(chan struct{})
 
+	if len(queued) > 0 {
+		tvdata.Queue = &mediaQueue{
+			items:         queued,
+			server:        s,
+			whereToListen: whereToListen,
+			pathPrefix:    policy.PathPrefix(),
+		}
+	}
+
 	// We pass the tvdata here as we need the callback handlers to be able to react
 	// to the different media renderer states.
-	var finalErr error
+	serveErr := make(chan error, 1)
+	served = true
 	go func() {
-		finalErr = s.ServeFiles(serverStarted, mediaBody, subTitleBody, tvdata, scr)
+		serveErr <- s.ServeFiles(serverStarted, mediaBody, subTitleBody, tvdata, scr)
 	}()
+
 	// Wait for HTTP server to properly initialize
-	<-serverStarted
+	select {
+	case <-serverStarted:
+	case err := <-serveErr:
+		return err
+	}
 
-	if err = scr.InterInit(tvdata); err != nil {
+	err = scr.InterInit(tvdata)
+
+	// Give the media renderer a moment to finish
+	// the transfers in flight before cutting them.
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	s.Shutdown(ctx)
+
+	if err != nil {
 		return err
 	}
 
-	return finalErr
+	return <-serveErr
 }
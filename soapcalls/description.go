@@ -0,0 +1,46 @@
+package soapcalls
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+)
+
+// GetDeviceDescription - Get the device description
+// of the specific DMR url.
+func GetDeviceDescription(dmr string) (*Root, error) {
+	return GetDeviceDescriptionContext(context.Background(), nil, dmr)
+}
+
+// GetDeviceDescriptionContext - Same as GetDeviceDescription, bound to the ctx.
+// If client is nil, a client with a 10 seconds timeout is used.
+func GetDeviceDescriptionContext(ctx context.Context, client *http.Client, dmr string) (*Root, error) {
+	if client == nil {
+		client = defaultHTTPClient()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, dmr, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create NewRequest for GetDeviceDescription: %w", err)
+	}
+
+	req.Header.Set("Connection", "close")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send HTTP request for GetDeviceDescription: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GetDeviceDescription bad status code: %s", resp.Status)
+	}
+
+	var root Root
+	if err = xml.NewDecoder(resp.Body).Decode(&root); err != nil {
+		return nil, fmt.Errorf("failed to read response body for GetDeviceDescription: %w", err)
+	}
+
+	return &root, nil
+}
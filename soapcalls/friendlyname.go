@@ -1,6 +1,7 @@
 package soapcalls
 
 import (
+	"context"
 	"encoding/xml"
 	"fmt"
 	"net/http"
@@ -15,8 +16,17 @@ type friendlyName struct {
 // GetFriendlyName - Get the friendly name value
 // for a the specific DMR url.
 func GetFriendlyName(dmr string) (string, error) {
-	client := &http.Client{}
-	req, err := http.NewRequest(http.MethodGet, dmr, nil)
+	return GetFriendlyNameContext(context.Background(), nil, dmr)
+}
+
+// GetFriendlyNameContext - Same as GetFriendlyName, bound to the ctx.
+// If client is nil, a client with a 10 seconds timeout is used.
+func GetFriendlyNameContext(ctx context.Context, client *http.Client, dmr string) (string, error) {
+	if client == nil {
+		client = defaultHTTPClient()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, dmr, nil)
 	if err != nil {
 		return "", fmt.Errorf("failed to create NewRequest for GetFriendlyName: %w", err)
 	}
@@ -0,0 +1,42 @@
+package soapcalls
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/hashicorp/go-retryablehttp"
+)
+
+// defaultTimeout - A media renderer that accepts the connection
+// but never replies should not block us forever.
+const defaultTimeout = 10 * time.Second
+
+func defaultHTTPClient() *http.Client {
+	return &http.Client{Timeout: defaultTimeout}
+}
+
+func (p *TVPayload) client() *http.Client {
+	if p.HTTPClient != nil {
+		return p.HTTPClient
+	}
+	return defaultHTTPClient()
+}
+
+// newRetryClient - Build a client on top of base that retries the
+// failed requests. SOAP Faults are sent with a 500 status code and
+// are final, so there is no point retrying those.
+func newRetryClient(base *http.Client) *http.Client {
+	retryClient := retryablehttp.NewClient()
+	retryClient.HTTPClient = base
+	retryClient.RetryMax = 3
+	retryClient.Logger = nil
+	retryClient.CheckRetry = func(ctx context.Context, resp *http.Response, err error) (bool, error) {
+		if err == nil && resp.StatusCode == http.StatusInternalServerError {
+			return false, nil
+		}
+		return retryablehttp.DefaultRetryPolicy(ctx, resp, err)
+	}
+
+	return retryClient.StandardClient()
+}
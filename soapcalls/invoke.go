@@ -0,0 +1,129 @@
+package soapcalls
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+
+	"github.com/pkg/errors"
+)
+
+// soapResponse - Output arguments of any action response.
+type soapResponse struct {
+	XMLName xml.Name
+	Args    []soapArg `xml:",any"`
+}
+
+// InvokeAction - Call any action of the serviceType service on the
+// controlURL, including vendor specific ones. The args are sent
+// in the given order. If out is not nil, the output arguments
+// are decoded into it. out can either be a *map[string]string or
+// a pointer to a struct with fields named (or xml tagged) after
+// the output arguments.
+func InvokeAction(controlURL, serviceType, action string, args []ActionArg, out interface{}) error {
+	return InvokeActionContext(context.Background(), nil, controlURL, serviceType, action, args, out)
+}
+
+// InvokeActionContext - Same as InvokeAction, bound to the ctx.
+// If client is nil, a client with a 10 seconds timeout is used.
+func InvokeActionContext(ctx context.Context, client *http.Client, controlURL, serviceType, action string, args []ActionArg, out interface{}) error {
+	xmlbuilder, err := soapActionBuild(serviceType, action, args)
+	if err != nil {
+		return fmt.Errorf("InvokeAction build error: %w", err)
+	}
+
+	if client == nil {
+		client = defaultHTTPClient()
+	}
+
+	if err := soapCall(ctx, client, controlURL, serviceType, action, xmlbuilder, out); err != nil {
+		return fmt.Errorf("InvokeAction error: %w", err)
+	}
+
+	return nil
+}
+
+// soapCall - POST the SOAP envelope to the controlURL
+// and decode the output arguments into out.
+func soapCall(ctx context.Context, client *http.Client, controlURL, serviceType, action string, body []byte, out interface{}) error {
+	parsedURLcontrol, err := url.Parse(controlURL)
+	if err != nil {
+		return fmt.Errorf("%s parse error: %w", action, err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", parsedURLcontrol.String(), bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("%s POST error: %w", action, err)
+	}
+
+	req.Header = http.Header{
+		"SOAPAction":   []string{`"` + serviceType + `#` + action + `"`},
+		"content-type": []string{"text/xml"},
+		"charset":      []string{"utf-8"},
+		"Connection":   []string{"close"},
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s Do POST error: %w", action, err)
+	}
+	defer resp.Body.Close()
+
+	if err := checkSoapResponse(action, resp); err != nil {
+		return err
+	}
+
+	if out == nil {
+		return nil
+	}
+
+	if err := decodeActionResponse(resp.Body, out); err != nil {
+		return fmt.Errorf("%s XML Decode error: %w", action, err)
+	}
+
+	return nil
+}
+
+// decodeActionResponse - Find the <u:actionResponse> element
+// in the SOAP envelope and decode its output arguments.
+func decodeActionResponse(r io.Reader, out interface{}) error {
+	dec := xml.NewDecoder(r)
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return errors.New("no action response in SOAP body")
+		}
+		if err != nil {
+			return err
+		}
+
+		start, ok := tok.(xml.StartElement)
+		if !ok || start.Name.Local == "Envelope" || start.Name.Local == "Body" {
+			continue
+		}
+
+		m, isMap := out.(*map[string]string)
+		if !isMap {
+			return dec.DecodeElement(out, &start)
+		}
+
+		var resp soapResponse
+		if err := dec.DecodeElement(&resp, &start); err != nil {
+			return err
+		}
+
+		if *m == nil {
+			*m = make(map[string]string)
+		}
+
+		for _, a := range resp.Args {
+			(*m)[a.XMLName.Local] = a.Value
+		}
+
+		return nil
+	}
+}
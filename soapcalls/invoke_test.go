@@ -0,0 +1,50 @@
+package soapcalls
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestInvokeAction(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if r.Header.Get("SOAPAction") != `"urn:schemas-upnp-org:service:RenderingControl:1#X_GetPictureMode"` {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if !strings.Contains(string(body), `<u:X_GetPictureMode xmlns:u="urn:schemas-upnp-org:service:RenderingControl:1"><InstanceID>0</InstanceID><Channel>Master</Channel></u:X_GetPictureMode>`) {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		io.WriteString(w, `<?xml version="1.0"?><s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/" s:encodingStyle="http://schemas.xmlsoap.org/soap/encoding/"><s:Body><u:X_GetPictureModeResponse xmlns:u="urn:schemas-upnp-org:service:RenderingControl:1"><PictureMode>Movie</PictureMode><Level>7</Level></u:X_GetPictureModeResponse></s:Body></s:Envelope>`)
+	}))
+	defer srv.Close()
+
+	args := []ActionArg{{"InstanceID", "0"}, {"Channel", "Master"}}
+
+	var outStruct struct {
+		PictureMode string
+		Level       int
+	}
+	if err := InvokeAction(srv.URL, RenderingControlService, "X_GetPictureMode", args, &outStruct); err != nil {
+		t.Fatalf("InvokeAction struct: Failed due to %s", err)
+	}
+	if outStruct.PictureMode != "Movie" || outStruct.Level != 7 {
+		t.Errorf("InvokeAction struct: got: %+v", outStruct)
+	}
+
+	var outMap map[string]string
+	if err := InvokeAction(srv.URL, RenderingControlService, "X_GetPictureMode", args, &outMap); err != nil {
+		t.Fatalf("InvokeAction map: Failed due to %s", err)
+	}
+	if outMap["PictureMode"] != "Movie" || outMap["Level"] != "7" {
+		t.Errorf("InvokeAction map: got: %v", outMap)
+	}
+
+	if err := InvokeAction(srv.URL, AVTransportService, "X_GetPictureMode", args, nil); err == nil {
+		t.Errorf("InvokeAction: expected error for wrong service type")
+	}
+}
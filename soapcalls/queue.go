@@ -0,0 +1,215 @@
+package soapcalls
+
+import (
+	"context"
+	"fmt"
+)
+
+// MediaQueue - The media items to cast after the current one.
+// Implementations are expected to make each item available
+// to the media renderer (e.g. register it with the HTTP server)
+// before returning it, and to stop serving it once released.
+type MediaQueue interface {
+	Next() (*QueueItem, bool)
+	// Release - Called once the media renderer is done with an
+	// item, either because the next item replaced it or because
+	// the media renderer rejected it.
+	Release(item *QueueItem)
+}
+
+// QueueItem - A queued media item as seen by the media renderer.
+type QueueItem struct {
+	MediaURL     string
+	MediaType    string
+	SubtitlesURL string
+}
+
+// CurrentMediaURL - Return the URL of the media item
+// that is currently handed over to the media renderer.
+func (p *TVPayload) CurrentMediaURL() string {
+	p.queueMu.RLock()
+	defer p.queueMu.RUnlock()
+	return p.MediaURL
+}
+
+// PreloadNextSoapCall - Fetch the next item from the queue and
+// preload it on the media renderer via SetNextAVTransportURI.
+// The item is kept as pending even if the media renderer rejects
+// it, so that AdvanceQueueSoapCall can fall back to
+// SetAVTransportURI once the current item has stopped.
+func (p *TVPayload) PreloadNextSoapCall() error {
+	return p.PreloadNextSoapCallContext(context.Background())
+}
+
+// PreloadNextSoapCallContext - Same as PreloadNextSoapCall, bound to the ctx.
+func (p *TVPayload) PreloadNextSoapCallContext(ctx context.Context) error {
+	if p.Queue == nil {
+		return nil
+	}
+
+	item, ok := p.Queue.Next()
+
+	p.queueMu.Lock()
+	p.nextItem = nil
+	if ok {
+		p.nextItem = item
+	}
+	p.queueMu.Unlock()
+
+	if !ok {
+		return nil
+	}
+
+	if err := p.setNextAVTransportSoapCall(ctx, item); err != nil {
+		return fmt.Errorf("PreloadNextSoapCall error: %w", err)
+	}
+
+	return nil
+}
+
+// SyncQueueSoapCall - Check if the media renderer moved on to
+// the preloaded item on its own and, if so, make it the current
+// item and preload the one after it. Returns true if the
+// current item changed.
+func (p *TVPayload) SyncQueueSoapCall() (bool, error) {
+	return p.SyncQueueSoapCallContext(context.Background())
+}
+
+// SyncQueueSoapCallContext - Same as SyncQueueSoapCall, bound to the ctx.
+func (p *TVPayload) SyncQueueSoapCallContext(ctx context.Context) (bool, error) {
+	p.queueMu.RLock()
+	next := p.nextItem
+	p.queueMu.RUnlock()
+
+	if next == nil {
+		return false, nil
+	}
+
+	pos, err := p.GetPositionInfoSoapCallContext(ctx)
+	if err != nil {
+		return false, fmt.Errorf("SyncQueueSoapCall position error: %w", err)
+	}
+
+	if pos.TrackURI != next.MediaURL {
+		return false, nil
+	}
+
+	p.promoteNext(next)
+
+	if err := p.PreloadNextSoapCallContext(ctx); err != nil {
+		return true, fmt.Errorf("SyncQueueSoapCall preload error: %w", err)
+	}
+
+	return true, nil
+}
+
+// AdvanceQueueSoapCall - Move on to the pending item once the
+// current one has stopped. This is the fallback for media renderers
+// that don't support SetNextAVTransportURI. Returns false if
+// there is no item left to play.
+func (p *TVPayload) AdvanceQueueSoapCall() (bool, error) {
+	return p.AdvanceQueueSoapCallContext(context.Background())
+}
+
+// AdvanceQueueSoapCallContext - Same as AdvanceQueueSoapCall, bound to the ctx.
+func (p *TVPayload) AdvanceQueueSoapCallContext(ctx context.Context) (bool, error) {
+	// The media renderer might have already
+	// handed over to the preloaded item.
+	changed, err := p.SyncQueueSoapCallContext(ctx)
+	if changed {
+		return true, err
+	}
+
+	p.queueMu.Lock()
+	next := p.nextItem
+	p.nextItem = nil
+	p.queueMu.Unlock()
+
+	if next == nil {
+		return false, nil
+	}
+
+	// Items the media renderer rejects are skipped in favor of
+	// the ones after them. The current item stays as is until
+	// one is accepted.
+	for {
+		err := p.setAVTransportItemSoapCall(ctx, next)
+		if err == nil {
+			break
+		}
+
+		p.Queue.Release(next)
+
+		if next = p.nextQueueItem(); next == nil {
+			return false, fmt.Errorf("AdvanceQueueSoapCall set AVT Transport error: %w", err)
+		}
+	}
+
+	p.promoteNext(next)
+
+	if err := p.playStopPauseSoapCall(ctx, "Play"); err != nil {
+		return false, fmt.Errorf("AdvanceQueueSoapCall Play action error: %w", err)
+	}
+
+	if err := p.PreloadNextSoapCallContext(ctx); err != nil {
+		return true, fmt.Errorf("AdvanceQueueSoapCall preload error: %w", err)
+	}
+
+	return true, nil
+}
+
+// nextQueueItem - Return the next item of the queue, or nil
+// if there is none.
+func (p *TVPayload) nextQueueItem() *QueueItem {
+	if p.Queue == nil {
+		return nil
+	}
+
+	item, ok := p.Queue.Next()
+	if !ok {
+		return nil
+	}
+
+	return item
+}
+
+// promoteNext - Make next the current item
+// and release the one it replaces.
+func (p *TVPayload) promoteNext(next *QueueItem) {
+	p.queueMu.Lock()
+	prev := &QueueItem{
+		MediaURL:     p.MediaURL,
+		MediaType:    p.MediaType,
+		SubtitlesURL: p.SubtitlesURL,
+	}
+	p.MediaURL = next.MediaURL
+	p.MediaType = next.MediaType
+	p.SubtitlesURL = next.SubtitlesURL
+	p.nextItem = nil
+	p.queueMu.Unlock()
+
+	if p.Queue != nil {
+		p.Queue.Release(prev)
+	}
+}
+
+func (p *TVPayload) setNextAVTransportSoapCall(ctx context.Context, item *QueueItem) error {
+	protocolInfo, err := p.protocolInfo(ctx, item.MediaType)
+	if err != nil {
+		return fmt.Errorf("setNextAVTransportSoapCall error: %w", err)
+	}
+
+	xml, err := setNextAVTransportSoapBuild(item.MediaURL, item.MediaType, item.SubtitlesURL, protocolInfo)
+	if err != nil {
+		return fmt.Errorf("setNextAVTransportSoapCall soap build error: %w", err)
+	}
+
+	// Media renderers without gapless support reply
+	// with a 401 Invalid Action or 501 error.
+	err = soapCall(ctx, p.client(), p.ControlURL, AVTransportService, "SetNextAVTransportURI", xml, nil)
+	if err != nil {
+		return fmt.Errorf("setNextAVTransportSoapCall error: %w", err)
+	}
+
+	return nil
+}
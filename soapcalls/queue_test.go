@@ -0,0 +1,113 @@
+package soapcalls
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type sliceQueue struct {
+	items    []*QueueItem
+	released []string
+}
+
+func (q *sliceQueue) Next() (*QueueItem, bool) {
+	if len(q.items) == 0 {
+		return nil, false
+	}
+
+	item := q.items[0]
+	q.items = q.items[1:]
+
+	return item, true
+}
+
+func (q *sliceQueue) Release(item *QueueItem) {
+	q.released = append(q.released, item.MediaURL)
+}
+
+func TestAdvanceQueueSkipsRejectedItems(t *testing.T) {
+	fault := `<?xml version="1.0"?><s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/"><s:Body><s:Fault>` +
+		`<faultcode>s:Client</faultcode><faultstring>UPnPError</faultstring><detail><UPnPError xmlns="urn:schemas-upnp-org:control-1-0">` +
+		`<errorCode>714</errorCode><errorDescription>Illegal MIME-type</errorDescription></UPnPError></detail></s:Fault></s:Body></s:Envelope>`
+
+	var played []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		action := r.Header.Get("SOAPAction")
+
+		switch {
+		case strings.Contains(action, "#SetAVTransportURI"):
+			if strings.Contains(string(body), "rejected") {
+				w.WriteHeader(http.StatusInternalServerError)
+				io.WriteString(w, fault)
+				return
+			}
+			start := strings.Index(string(body), "<CurrentURI>") + len("<CurrentURI>")
+			played = append(played, string(body)[start:start+strings.Index(string(body)[start:], "<")])
+		case strings.Contains(action, "#SetNextAVTransportURI"):
+			w.WriteHeader(http.StatusInternalServerError)
+			io.WriteString(w, fault)
+			return
+		case strings.Contains(action, "#GetPositionInfo"):
+			io.WriteString(w, `<?xml version="1.0"?><s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/"><s:Body>`+
+				`<u:GetPositionInfoResponse xmlns:u="urn:schemas-upnp-org:service:AVTransport:1"><TrackURI>http://192.168.88.250:3500/first.mp4</TrackURI></u:GetPositionInfoResponse></s:Body></s:Envelope>`)
+			return
+		}
+
+		io.WriteString(w, `<?xml version="1.0"?><s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/"><s:Body></s:Body></s:Envelope>`)
+	}))
+	defer srv.Close()
+
+	queue := &sliceQueue{items: []*QueueItem{
+		{MediaURL: "http://192.168.88.250:3500/rejected-1.mp4"},
+		{MediaURL: "http://192.168.88.250:3500/rejected-2.mp4"},
+		{MediaURL: "http://192.168.88.250:3500/accepted.mp4"},
+	}}
+
+	tv := &TVPayload{
+		ControlURL: srv.URL,
+		MediaURL:   "http://192.168.88.250:3500/first.mp4",
+		Queue:      queue,
+	}
+
+	// The first rejected item becomes the pending one.
+	tv.PreloadNextSoapCall()
+
+	advanced, err := tv.AdvanceQueueSoapCall()
+	if !advanced {
+		t.Fatalf("AdvanceQueueSoapCall: got: not advanced (%v), want: advanced", err)
+	}
+
+	if got := tv.CurrentMediaURL(); got != "http://192.168.88.250:3500/accepted.mp4" {
+		t.Errorf("AdvanceQueueSoapCall: got: %s, want: the accepted item", got)
+	}
+
+	if len(played) != 1 || played[0] != "http://192.168.88.250:3500/accepted.mp4" {
+		t.Errorf("AdvanceQueueSoapCall: got: %v set, want: only the accepted item", played)
+	}
+
+	wantReleased := "http://192.168.88.250:3500/rejected-1.mp4 http://192.168.88.250:3500/rejected-2.mp4 http://192.168.88.250:3500/first.mp4"
+	if got := strings.Join(queue.released, " "); got != wantReleased {
+		t.Errorf("AdvanceQueueSoapCall: got: %s released, want: %s", got, wantReleased)
+	}
+
+	// Nothing left, and nothing accepted.
+	queue = &sliceQueue{items: []*QueueItem{{MediaURL: "http://192.168.88.250:3500/rejected-3.mp4"}}}
+	tv.Queue = queue
+	tv.PreloadNextSoapCall()
+
+	if advanced, err := tv.AdvanceQueueSoapCall(); advanced || err == nil {
+		t.Errorf("AdvanceQueueSoapCall: got: %t, %v, want: false and an error", advanced, err)
+	}
+
+	if got := tv.CurrentMediaURL(); got != "http://192.168.88.250:3500/accepted.mp4" {
+		t.Errorf("AdvanceQueueSoapCall: got: %s, want: the last accepted item", got)
+	}
+
+	if got := strings.Join(queue.released, " "); got != "http://192.168.88.250:3500/rejected-3.mp4" {
+		t.Errorf("AdvanceQueueSoapCall: got: %s released, want: the rejected item", got)
+	}
+}
@@ -5,105 +5,55 @@ import (
 	"encoding/xml"
 	"fmt"
 	"net/url"
+	"path"
 	"regexp"
 	"strings"
 
 	"github.com/pkg/errors"
 )
 
-// PlayEnvelope - As in Play Pause Stop.
-type PlayEnvelope struct {
-	XMLName  xml.Name `xml:"s:Envelope"`
-	Schema   string   `xml:"xmlns:s,attr"`
-	Encoding string   `xml:"s:encodingStyle,attr"`
-	PlayBody PlayBody `xml:"s:Body"`
-}
-
-// PlayBody .
-type PlayBody struct {
-	XMLName    xml.Name   `xml:"s:Body"`
-	PlayAction PlayAction `xml:"u:Play"`
-}
-
-// PlayAction .
-type PlayAction struct {
-	XMLName     xml.Name `xml:"u:Play"`
-	AVTransport string   `xml:"xmlns:u,attr"`
-	InstanceID  string
-	Speed       string
-}
-
-// PauseEnvelope - As in Play Pause Stop.
-type PauseEnvelope struct {
-	XMLName   xml.Name  `xml:"s:Envelope"`
-	Schema    string    `xml:"xmlns:s,attr"`
-	Encoding  string    `xml:"s:encodingStyle,attr"`
-	PauseBody PauseBody `xml:"s:Body"`
-}
+// Service types of the UPnP services we talk to.
+const (
+	AVTransportService       = "urn:schemas-upnp-org:service:AVTransport:1"
+	RenderingControlService  = "urn:schemas-upnp-org:service:RenderingControl:1"
+	ConnectionManagerService = "urn:schemas-upnp-org:service:ConnectionManager:1"
+)
 
-// PauseBody .
-type PauseBody struct {
-	XMLName     xml.Name    `xml:"s:Body"`
-	PauseAction PauseAction `xml:"u:Pause"`
-}
+var seekTargetRe = regexp.MustCompile(`^\d+:\d{2}:\d{2}(\.\d+)?$`)
 
-// PauseAction .
-type PauseAction struct {
-	XMLName     xml.Name `xml:"u:Pause"`
-	AVTransport string   `xml:"xmlns:u,attr"`
-	InstanceID  string
-	Speed       string
+// ActionArg - An input argument of a UPnP action. The arguments
+// are sent in the order they are given, as the UPnP specification
+// requires and some media renderers enforce.
+type ActionArg struct {
+	Name  string
+	Value string
 }
 
-// StopEnvelope - As in Play Pause Stop.
-type StopEnvelope struct {
+// soapEnvelope - A SOAP envelope that can carry any action.
+type soapEnvelope struct {
 	XMLName  xml.Name `xml:"s:Envelope"`
 	Schema   string   `xml:"xmlns:s,attr"`
 	Encoding string   `xml:"s:encodingStyle,attr"`
-	StopBody StopBody `xml:"s:Body"`
-}
-
-// StopBody .
-type StopBody struct {
-	XMLName    xml.Name   `xml:"s:Body"`
-	StopAction StopAction `xml:"u:Stop"`
+	Body     soapBody `xml:"s:Body"`
 }
 
-// StopAction .
-type StopAction struct {
-	XMLName     xml.Name `xml:"u:Stop"`
-	AVTransport string   `xml:"xmlns:u,attr"`
-	InstanceID  string
-	Speed       string
+// soapBody .
+type soapBody struct {
+	XMLName xml.Name `xml:"s:Body"`
+	Action  soapAction
 }
 
-// SetAVTransportEnvelope .
-type SetAVTransportEnvelope struct {
-	XMLName  xml.Name           `xml:"s:Envelope"`
-	Schema   string             `xml:"xmlns:s,attr"`
-	Encoding string             `xml:"s:encodingStyle,attr"`
-	Body     SetAVTransportBody `xml:"s:Body"`
+// soapAction - The element name is set at runtime to u:<action>.
+type soapAction struct {
+	XMLName xml.Name
+	Service string `xml:"xmlns:u,attr"`
+	Args    []soapArg
 }
 
-// SetAVTransportBody .
-type SetAVTransportBody struct {
-	XMLName           xml.Name          `xml:"s:Body"`
-	SetAVTransportURI SetAVTransportURI `xml:"u:SetAVTransportURI"`
-}
-
-// SetAVTransportURI .
-type SetAVTransportURI struct {
-	XMLName            xml.Name `xml:"u:SetAVTransportURI"`
-	AVTransport        string   `xml:"xmlns:u,attr"`
-	InstanceID         string
-	CurrentURI         string
-	CurrentURIMetaData CurrentURIMetaData `xml:"CurrentURIMetaData"`
-}
-
-// CurrentURIMetaData .
-type CurrentURIMetaData struct {
-	XMLName xml.Name `xml:"CurrentURIMetaData"`
-	Value   []byte   `xml:",chardata"`
+// soapArg - The element name is set at runtime to the argument name.
+type soapArg struct {
+	XMLName xml.Name
+	Value   string `xml:",chardata"`
 }
 
 // DIDLLite .
@@ -150,97 +100,88 @@ type SecCaptionInfoEx struct {
 	Value   string   `xml:",chardata"`
 }
 
-// SetMuteEnvelope - As in Play Pause Stop.
-type SetMuteEnvelope struct {
-	XMLName     xml.Name    `xml:"s:Envelope"`
-	Schema      string      `xml:"xmlns:s,attr"`
-	Encoding    string      `xml:"s:encodingStyle,attr"`
-	SetMuteBody SetMuteBody `xml:"s:Body"`
-}
-
-// SetMuteBody .
-type SetMuteBody struct {
-	XMLName       xml.Name      `xml:"s:Body"`
-	SetMuteAction SetMuteAction `xml:"u:SetMute"`
-}
-
-// SetMuteAction .
-type SetMuteAction struct {
-	XMLName          xml.Name `xml:"u:SetMute"`
-	RenderingControl string   `xml:"xmlns:u,attr"`
-	InstanceID       string
-	Channel          string
-	DesiredMute      string
-}
+// soapActionBuild - Build the SOAP envelope for any action
+// of the serviceType service.
+func soapActionBuild(serviceType, action string, args []ActionArg) ([]byte, error) {
+	soapArgs := make([]soapArg, 0, len(args))
+	for _, a := range args {
+		soapArgs = append(soapArgs, soapArg{
+			XMLName: xml.Name{Local: a.Name},
+			Value:   a.Value,
+		})
+	}
 
-// GetMuteEnvelope - As in Play Pause Stop.
-type GetMuteEnvelope struct {
-	XMLName     xml.Name    `xml:"s:Envelope"`
-	Schema      string      `xml:"xmlns:s,attr"`
-	Encoding    string      `xml:"s:encodingStyle,attr"`
-	GetMuteBody GetMuteBody `xml:"s:Body"`
-}
+	d := soapEnvelope{
+		XMLName:  xml.Name{},
+		Schema:   "http://schemas.xmlsoap.org/soap/envelope/",
+		Encoding: "http://schemas.xmlsoap.org/soap/encoding/",
+		Body: soapBody{
+			XMLName: xml.Name{},
+			Action: soapAction{
+				XMLName: xml.Name{Local: "u:" + action},
+				Service: serviceType,
+				Args:    soapArgs,
+			},
+		},
+	}
+	xmlStart := []byte("<?xml version='1.0' encoding='utf-8'?>")
+	b, err := xml.Marshal(d)
+	if err != nil {
+		return nil, fmt.Errorf("soapActionBuild %s Marshal error: %w", action, err)
+	}
 
-// GetMuteBody .
-type GetMuteBody struct {
-	XMLName       xml.Name      `xml:"s:Body"`
-	GetMuteAction GetMuteAction `xml:"u:GetMute"`
+	return append(xmlStart, b...), nil
 }
 
-// GetMuteAction .
-type GetMuteAction struct {
-	XMLName          xml.Name `xml:"u:GetMute"`
-	RenderingControl string   `xml:"xmlns:u,attr"`
-	InstanceID       string
-	Channel          string
-}
+func setAVTransportSoapBuild(mediaURL, mediaType, subtitleURL, protocolInfo string) ([]byte, error) {
+	a, err := didlLiteBuild(mediaURL, mediaType, subtitleURL, protocolInfo)
+	if err != nil {
+		return nil, fmt.Errorf("setAVTransportSoapBuild #1 Marshal error: %w", err)
+	}
 
-// GetVolumeEnvelope .
-type GetVolumeEnvelope struct {
-	XMLName       xml.Name      `xml:"s:Envelope"`
-	Schema        string        `xml:"xmlns:s,attr"`
-	Encoding      string        `xml:"s:encodingStyle,attr"`
-	GetVolumeBody GetVolumeBody `xml:"s:Body"`
-}
+	b, err := soapActionBuild(AVTransportService, "SetAVTransportURI", []ActionArg{
+		{"InstanceID", "0"},
+		{"CurrentURI", mediaURL},
+		{"CurrentURIMetaData", string(a)},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("setAVTransportSoapBuild #2 Marshal error: %w", err)
+	}
 
-// GetVolumeBody .
-type GetVolumeBody struct {
-	XMLName         xml.Name        `xml:"s:Body"`
-	GetVolumeAction GetVolumeAction `xml:"u:GetVolume"`
+	return samsungHack(b), nil
 }
 
-// GetVolumeAction .
-type GetVolumeAction struct {
-	XMLName          xml.Name `xml:"u:GetVolume"`
-	RenderingControl string   `xml:"xmlns:u,attr"`
-	InstanceID       string
-	Channel          string
-}
+func setNextAVTransportSoapBuild(mediaURL, mediaType, subtitleURL, protocolInfo string) ([]byte, error) {
+	a, err := didlLiteBuild(mediaURL, mediaType, subtitleURL, protocolInfo)
+	if err != nil {
+		return nil, fmt.Errorf("setNextAVTransportSoapBuild #1 Marshal error: %w", err)
+	}
 
-// SetVolumeEnvelope - As in Play Pause Stop.
-type SetVolumeEnvelope struct {
-	XMLName       xml.Name      `xml:"s:Envelope"`
-	Schema        string        `xml:"xmlns:s,attr"`
-	Encoding      string        `xml:"s:encodingStyle,attr"`
-	SetVolumeBody SetVolumeBody `xml:"s:Body"`
-}
+	b, err := soapActionBuild(AVTransportService, "SetNextAVTransportURI", []ActionArg{
+		{"InstanceID", "0"},
+		{"NextURI", mediaURL},
+		{"NextURIMetaData", string(a)},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("setNextAVTransportSoapBuild #2 Marshal error: %w", err)
+	}
 
-// SetVolumeBody .
-type SetVolumeBody struct {
-	XMLName         xml.Name        `xml:"s:Body"`
-	SetVolumeAction SetVolumeAction `xml:"u:SetVolume"`
+	return samsungHack(b), nil
 }
 
-// SetVolumeAction .
-type SetVolumeAction struct {
-	XMLName          xml.Name `xml:"u:SetVolume"`
-	RenderingControl string   `xml:"xmlns:u,attr"`
-	InstanceID       string
-	Channel          string
-	DesiredVolume    string
+// samsungHack - Samsung TVs fail to parse the metadata
+// unless the quotes and ampersands are left unescaped.
+func samsungHack(b []byte) []byte {
+	b = bytes.ReplaceAll(b, []byte("&#34;"), []byte(`"`))
+	b = bytes.ReplaceAll(b, []byte("&amp;"), []byte("&"))
+	return b
 }
 
-func setAVTransportSoapBuild(mediaURL, mediaType, subtitleURL string) ([]byte, error) {
+// didlLiteBuild - Build the DIDL-Lite metadata that
+// describes the media item to the media renderer.
+// If protocolInfo is empty, a generic one is built
+// out of the mediaType.
+func didlLiteBuild(mediaURL, mediaType, subtitleURL, protocolInfo string) ([]byte, error) {
 	mediaTypeSlice := strings.Split(mediaType, "/")
 
 	var class string
@@ -253,18 +194,24 @@ func setAVTransportSoapBuild(mediaURL, mediaType, subtitleURL string) ([]byte, e
 		class = "object.item.videoItem.movie"
 	}
 
+	// The media paths carry the session token,
+	// only the file name makes for a title.
 	mediaTitle := mediaURL
 	mediaTitlefromURL, err := url.Parse(mediaURL)
 	if err == nil {
-		mediaTitle = strings.TrimLeft(mediaTitlefromURL.Path, "/")
+		mediaTitle = path.Base(mediaTitlefromURL.Path)
 	}
 
 	re, err := regexp.Compile(`[&<>\\]+`)
 	if err != nil {
-		return nil, fmt.Errorf("setAVTransportSoapBuild regex compile error: %w", err)
+		return nil, fmt.Errorf("didlLiteBuild regex compile error: %w", err)
 	}
 	mediaTitle = re.ReplaceAllString(mediaTitle, "")
 
+	if protocolInfo == "" {
+		protocolInfo = fmt.Sprintf("http-get:*:%s:*", mediaType)
+	}
+
 	l := DIDLLite{
 		XMLName:    xml.Name{},
 		SchemaDIDL: "urn:schemas-upnp-org:metadata-1-0/DIDL-Lite/",
@@ -281,7 +228,7 @@ func setAVTransportSoapBuild(mediaURL, mediaType, subtitleURL string) ([]byte, e
 			ResNode: []ResNode{
 				{
 					XMLName:      xml.Name{},
-					ProtocolInfo: fmt.Sprintf("http-get:*:%s:*", mediaType),
+					ProtocolInfo: protocolInfo,
 					Value:        mediaURL,
 				}, {
 					XMLName:      xml.Name{},
@@ -303,110 +250,35 @@ func setAVTransportSoapBuild(mediaURL, mediaType, subtitleURL string) ([]byte, e
 	}
 	a, err := xml.Marshal(l)
 	if err != nil {
-		return nil, fmt.Errorf("setAVTransportSoapBuild #1 Marshal error: %w", err)
+		return nil, fmt.Errorf("didlLiteBuild Marshal error: %w", err)
 	}
 
-	d := SetAVTransportEnvelope{
-		XMLName:  xml.Name{},
-		Schema:   "http://schemas.xmlsoap.org/soap/envelope/",
-		Encoding: "http://schemas.xmlsoap.org/soap/encoding/",
-		Body: SetAVTransportBody{
-			XMLName: xml.Name{},
-			SetAVTransportURI: SetAVTransportURI{
-				XMLName:     xml.Name{},
-				AVTransport: "urn:schemas-upnp-org:service:AVTransport:1",
-				InstanceID:  "0",
-				CurrentURI:  mediaURL,
-				CurrentURIMetaData: CurrentURIMetaData{
-					XMLName: xml.Name{},
-					Value:   a,
-				},
-			},
-		},
-	}
-	xmlStart := []byte("<?xml version='1.0' encoding='utf-8'?>")
-	b, err := xml.Marshal(d)
-	if err != nil {
-		return nil, fmt.Errorf("setAVTransportSoapBuild #2 Marshal error: %w", err)
-	}
-
-	// Samsung TV hack.
-	b = bytes.ReplaceAll(b, []byte("&#34;"), []byte(`"`))
-	b = bytes.ReplaceAll(b, []byte("&amp;"), []byte("&"))
+	return a, nil
+}
 
-	return append(xmlStart, b...), nil
+func getProtocolInfoSoapBuild() ([]byte, error) {
+	return soapActionBuild(ConnectionManagerService, "GetProtocolInfo", nil)
 }
 
 func playSoapBuild() ([]byte, error) {
-	d := PlayEnvelope{
-		XMLName:  xml.Name{},
-		Schema:   "http://schemas.xmlsoap.org/soap/envelope/",
-		Encoding: "http://schemas.xmlsoap.org/soap/encoding/",
-		PlayBody: PlayBody{
-			XMLName: xml.Name{},
-			PlayAction: PlayAction{
-				XMLName:     xml.Name{},
-				AVTransport: "urn:schemas-upnp-org:service:AVTransport:1",
-				InstanceID:  "0",
-				Speed:       "1",
-			},
-		},
-	}
-	xmlStart := []byte("<?xml version='1.0' encoding='utf-8'?>")
-	b, err := xml.Marshal(d)
-	if err != nil {
-		return nil, fmt.Errorf("playSoapBuild Marshal error: %w", err)
-	}
-
-	return append(xmlStart, b...), nil
+	return soapActionBuild(AVTransportService, "Play", []ActionArg{
+		{"InstanceID", "0"},
+		{"Speed", "1"},
+	})
 }
 
 func stopSoapBuild() ([]byte, error) {
-	d := StopEnvelope{
-		XMLName:  xml.Name{},
-		Schema:   "http://schemas.xmlsoap.org/soap/envelope/",
-		Encoding: "http://schemas.xmlsoap.org/soap/encoding/",
-		StopBody: StopBody{
-			XMLName: xml.Name{},
-			StopAction: StopAction{
-				XMLName:     xml.Name{},
-				AVTransport: "urn:schemas-upnp-org:service:AVTransport:1",
-				InstanceID:  "0",
-				Speed:       "1",
-			},
-		},
-	}
-	xmlStart := []byte("<?xml version='1.0' encoding='utf-8'?>")
-	b, err := xml.Marshal(d)
-	if err != nil {
-		return nil, fmt.Errorf("stopSoapBuild Marshal error: %w", err)
-	}
-
-	return append(xmlStart, b...), nil
+	return soapActionBuild(AVTransportService, "Stop", []ActionArg{
+		{"InstanceID", "0"},
+		{"Speed", "1"},
+	})
 }
 
 func pauseSoapBuild() ([]byte, error) {
-	d := PauseEnvelope{
-		XMLName:  xml.Name{},
-		Schema:   "http://schemas.xmlsoap.org/soap/envelope/",
-		Encoding: "http://schemas.xmlsoap.org/soap/encoding/",
-		PauseBody: PauseBody{
-			XMLName: xml.Name{},
-			PauseAction: PauseAction{
-				XMLName:     xml.Name{},
-				AVTransport: "urn:schemas-upnp-org:service:AVTransport:1",
-				InstanceID:  "0",
-				Speed:       "1",
-			},
-		},
-	}
-	xmlStart := []byte("<?xml version='1.0' encoding='utf-8'?>")
-	b, err := xml.Marshal(d)
-	if err != nil {
-		return nil, fmt.Errorf("pauseSoapBuild Marshal error: %w", err)
-	}
-
-	return append(xmlStart, b...), nil
+	return soapActionBuild(AVTransportService, "Pause", []ActionArg{
+		{"InstanceID", "0"},
+		{"Speed", "1"},
+	})
 }
 
 func setMuteSoapBuild(m string) ([]byte, error) {
@@ -414,99 +286,65 @@ func setMuteSoapBuild(m string) ([]byte, error) {
 		return nil, errors.New("setMuteSoapBuild input error. Was expecting 0 or 1.")
 	}
 
-	d := SetMuteEnvelope{
-		XMLName:  xml.Name{},
-		Schema:   "http://schemas.xmlsoap.org/soap/envelope/",
-		Encoding: "http://schemas.xmlsoap.org/soap/encoding/",
-		SetMuteBody: SetMuteBody{
-			XMLName: xml.Name{},
-			SetMuteAction: SetMuteAction{
-				XMLName:          xml.Name{},
-				RenderingControl: "urn:schemas-upnp-org:service:RenderingControl:1",
-				InstanceID:       "0",
-				Channel:          "Master",
-				DesiredMute:      m,
-			},
-		},
-	}
-	xmlStart := []byte("<?xml version='1.0' encoding='utf-8'?>")
-	b, err := xml.Marshal(d)
-	if err != nil {
-		return nil, fmt.Errorf("setMuteSoapBuild Marshal error: %w", err)
-	}
-
-	return append(xmlStart, b...), nil
+	return soapActionBuild(RenderingControlService, "SetMute", []ActionArg{
+		{"InstanceID", "0"},
+		{"Channel", "Master"},
+		{"DesiredMute", m},
+	})
 }
 
 func getMuteSoapBuild() ([]byte, error) {
-	d := GetMuteEnvelope{
-		XMLName:  xml.Name{},
-		Schema:   "http://schemas.xmlsoap.org/soap/envelope/",
-		Encoding: "http://schemas.xmlsoap.org/soap/encoding/",
-		GetMuteBody: GetMuteBody{
-			XMLName: xml.Name{},
-			GetMuteAction: GetMuteAction{
-				XMLName:          xml.Name{},
-				RenderingControl: "urn:schemas-upnp-org:service:RenderingControl:1",
-				InstanceID:       "0",
-				Channel:          "Master",
-			},
-		},
-	}
-	xmlStart := []byte("<?xml version='1.0' encoding='utf-8'?>")
-	b, err := xml.Marshal(d)
-	if err != nil {
-		return nil, fmt.Errorf("getMuteSoapBuild Marshal error: %w", err)
-	}
-
-	return append(xmlStart, b...), nil
+	return soapActionBuild(RenderingControlService, "GetMute", []ActionArg{
+		{"InstanceID", "0"},
+		{"Channel", "Master"},
+	})
 }
 
 func getVolumeSoapBuild() ([]byte, error) {
-	d := GetVolumeEnvelope{
-		XMLName:  xml.Name{},
-		Schema:   "http://schemas.xmlsoap.org/soap/envelope/",
-		Encoding: "http://schemas.xmlsoap.org/soap/encoding/",
-		GetVolumeBody: GetVolumeBody{
-			XMLName: xml.Name{},
-			GetVolumeAction: GetVolumeAction{
-				XMLName:          xml.Name{},
-				RenderingControl: "urn:schemas-upnp-org:service:RenderingControl:1",
-				InstanceID:       "0",
-				Channel:          "Master",
-			},
-		},
-	}
-	xmlStart := []byte("<?xml version='1.0' encoding='utf-8'?>")
-	b, err := xml.Marshal(d)
-	if err != nil {
-		return nil, fmt.Errorf("getVolumeSoapBuild Marshal error: %w", err)
-	}
-
-	return append(xmlStart, b...), nil
+	return soapActionBuild(RenderingControlService, "GetVolume", []ActionArg{
+		{"InstanceID", "0"},
+		{"Channel", "Master"},
+	})
 }
 
 func setVolumeSoapBuild(v string) ([]byte, error) {
-	d := SetVolumeEnvelope{
-		XMLName:  xml.Name{},
-		Schema:   "http://schemas.xmlsoap.org/soap/envelope/",
-		Encoding: "http://schemas.xmlsoap.org/soap/encoding/",
-		SetVolumeBody: SetVolumeBody{
-			XMLName: xml.Name{},
-			SetVolumeAction: SetVolumeAction{
-				XMLName:          xml.Name{},
-				RenderingControl: "urn:schemas-upnp-org:service:RenderingControl:1",
-				InstanceID:       "0",
-				Channel:          "Master",
-				DesiredVolume:    v,
-			},
-		},
+	return soapActionBuild(RenderingControlService, "SetVolume", []ActionArg{
+		{"InstanceID", "0"},
+		{"Channel", "Master"},
+		{"DesiredVolume", v},
+	})
+}
+
+func seekSoapBuild(unit, target string) ([]byte, error) {
+	if unit != "REL_TIME" && unit != "ABS_TIME" {
+		return nil, errors.New("seekSoapBuild input error. Was expecting REL_TIME or ABS_TIME.")
 	}
-	xmlStart := []byte("<?xml version='1.0' encoding='utf-8'?>")
-	b, err := xml.Marshal(d)
-	if err != nil {
-		return nil, fmt.Errorf("setVolumeSoapBuild Marshal error: %w", err)
+
+	if !seekTargetRe.MatchString(target) {
+		return nil, errors.New("seekSoapBuild input error. Was expecting a H+:MM:SS target.")
 	}
 
-	return append(xmlStart, b...), nil
+	return soapActionBuild(AVTransportService, "Seek", []ActionArg{
+		{"InstanceID", "0"},
+		{"Unit", unit},
+		{"Target", target},
+	})
+}
+
+func getPositionInfoSoapBuild() ([]byte, error) {
+	return soapActionBuild(AVTransportService, "GetPositionInfo", []ActionArg{
+		{"InstanceID", "0"},
+	})
+}
+
+func getTransportInfoSoapBuild() ([]byte, error) {
+	return soapActionBuild(AVTransportService, "GetTransportInfo", []ActionArg{
+		{"InstanceID", "0"},
+	})
+}
+
+func getMediaInfoSoapBuild() ([]byte, error) {
+	return soapActionBuild(AVTransportService, "GetMediaInfo", []ActionArg{
+		{"InstanceID", "0"},
+	})
 }
@@ -22,7 +22,7 @@ func TestSetAVTransportSoapBuild(t *testing.T) {
 	}
 
 	for _, tc := range tt {
-		out, err := setAVTransportSoapBuild(tc.mediaURL, tc.mediaType, tc.subtitleURL)
+		out, err := setAVTransportSoapBuild(tc.mediaURL, tc.mediaType, tc.subtitleURL, "")
 		if err != nil {
 			t.Errorf("%s: Failed to call setAVTransportSoapBuild due to %s", tc.name, err.Error())
 			return
@@ -88,3 +88,114 @@ func TestGetVolumeSoapBuild(t *testing.T) {
 		}
 	}
 }
+
+func TestSeekSoapBuild(t *testing.T) {
+	tt := []struct {
+		name   string
+		unit   string
+		target string
+		want   string
+	}{
+		{
+			`seekSoapBuild Test #1`,
+			"REL_TIME",
+			"0:01:30",
+			`<?xml version='1.0' encoding='utf-8'?><s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/" s:encodingStyle="http://schemas.xmlsoap.org/soap/encoding/"><s:Body><u:Seek xmlns:u="urn:schemas-upnp-org:service:AVTransport:1"><InstanceID>0</InstanceID><Unit>REL_TIME</Unit><Target>0:01:30</Target></u:Seek></s:Body></s:Envelope>`,
+		},
+		{
+			`seekSoapBuild Test #2`,
+			"ABS_TIME",
+			"01:00:00.000",
+			`<?xml version='1.0' encoding='utf-8'?><s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/" s:encodingStyle="http://schemas.xmlsoap.org/soap/encoding/"><s:Body><u:Seek xmlns:u="urn:schemas-upnp-org:service:AVTransport:1"><InstanceID>0</InstanceID><Unit>ABS_TIME</Unit><Target>01:00:00.000</Target></u:Seek></s:Body></s:Envelope>`,
+		},
+	}
+
+	for _, tc := range tt {
+		out, err := seekSoapBuild(tc.unit, tc.target)
+		if err != nil {
+			t.Errorf("%s: Failed to call seekSoapBuild due to %s", tc.name, err.Error())
+			return
+		}
+		if string(out) != tc.want {
+			t.Errorf("%s: got: %s, want: %s.", tc.name, out, tc.want)
+			return
+		}
+	}
+
+	if _, err := seekSoapBuild("TRACK_NR", "1"); err == nil {
+		t.Errorf("seekSoapBuild: expected error for unsupported unit")
+	}
+}
+
+func TestSetNextAVTransportSoapBuild(t *testing.T) {
+	tt := []struct {
+		name        string
+		mediaURL    string
+		mediaType   string
+		subtitleURL string
+		want        string
+	}{
+		{
+			`setNextAVTransportSoapBuild Test #1`,
+			`http://192.168.88.250:3500/song.mp3`,
+			"audio/mpeg",
+			"",
+			`<?xml version='1.0' encoding='utf-8'?><s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/" s:encodingStyle="http://schemas.xmlsoap.org/soap/encoding/"><s:Body><u:SetNextAVTransportURI xmlns:u="urn:schemas-upnp-org:service:AVTransport:1"><InstanceID>0</InstanceID><NextURI>http://192.168.88.250:3500/song.mp3</NextURI><NextURIMetaData>&lt;DIDL-Lite xmlns="urn:schemas-upnp-org:metadata-1-0/DIDL-Lite/" xmlns:dc="http://purl.org/dc/elements/1.1/" xmlns:sec="http://www.sec.co.kr/" xmlns:upnp="urn:schemas-upnp-org:metadata-1-0/upnp/"&gt;&lt;item restricted="false" id="0" parentID="-1"&gt;&lt;sec:CaptionInfo sec:type="srt"&gt;&lt;/sec:CaptionInfo&gt;&lt;sec:CaptionInfoEx sec:type="srt"&gt;&lt;/sec:CaptionInfoEx&gt;&lt;upnp:class&gt;object.item.audioItem.musicTrack&lt;/upnp:class&gt;&lt;dc:title&gt;song.mp3&lt;/dc:title&gt;&lt;res protocolInfo="http-get:*:audio/mpeg:*"&gt;http://192.168.88.250:3500/song.mp3&lt;/res&gt;&lt;res protocolInfo="http-get:*:text/srt:*"&gt;&lt;/res&gt;&lt;/item&gt;&lt;/DIDL-Lite&gt;</NextURIMetaData></u:SetNextAVTransportURI></s:Body></s:Envelope>`,
+		},
+		{
+			`setNextAVTransportSoapBuild Test #2`,
+			`http://192.168.88.250:3500/AbCdEf123/song.mp3`,
+			"audio/mpeg",
+			"",
+			`<?xml version='1.0' encoding='utf-8'?><s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/" s:encodingStyle="http://schemas.xmlsoap.org/soap/encoding/"><s:Body><u:SetNextAVTransportURI xmlns:u="urn:schemas-upnp-org:service:AVTransport:1"><InstanceID>0</InstanceID><NextURI>http://192.168.88.250:3500/AbCdEf123/song.mp3</NextURI><NextURIMetaData>&lt;DIDL-Lite xmlns="urn:schemas-upnp-org:metadata-1-0/DIDL-Lite/" xmlns:dc="http://purl.org/dc/elements/1.1/" xmlns:sec="http://www.sec.co.kr/" xmlns:upnp="urn:schemas-upnp-org:metadata-1-0/upnp/"&gt;&lt;item restricted="false" id="0" parentID="-1"&gt;&lt;sec:CaptionInfo sec:type="srt"&gt;&lt;/sec:CaptionInfo&gt;&lt;sec:CaptionInfoEx sec:type="srt"&gt;&lt;/sec:CaptionInfoEx&gt;&lt;upnp:class&gt;object.item.audioItem.musicTrack&lt;/upnp:class&gt;&lt;dc:title&gt;song.mp3&lt;/dc:title&gt;&lt;res protocolInfo="http-get:*:audio/mpeg:*"&gt;http://192.168.88.250:3500/AbCdEf123/song.mp3&lt;/res&gt;&lt;res protocolInfo="http-get:*:text/srt:*"&gt;&lt;/res&gt;&lt;/item&gt;&lt;/DIDL-Lite&gt;</NextURIMetaData></u:SetNextAVTransportURI></s:Body></s:Envelope>`,
+		},
+	}
+
+	for _, tc := range tt {
+		out, err := setNextAVTransportSoapBuild(tc.mediaURL, tc.mediaType, tc.subtitleURL, "")
+		if err != nil {
+			t.Errorf("%s: Failed to call setNextAVTransportSoapBuild due to %s", tc.name, err.Error())
+			return
+		}
+		if string(out) != tc.want {
+			t.Errorf("%s: got: %s, want: %s.", tc.name, out, tc.want)
+			return
+		}
+	}
+}
+
+func TestGetInfoSoapBuild(t *testing.T) {
+	tt := []struct {
+		name  string
+		build func() ([]byte, error)
+		want  string
+	}{
+		{
+			`getPositionInfoSoapBuild Test #1`,
+			getPositionInfoSoapBuild,
+			`<?xml version='1.0' encoding='utf-8'?><s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/" s:encodingStyle="http://schemas.xmlsoap.org/soap/encoding/"><s:Body><u:GetPositionInfo xmlns:u="urn:schemas-upnp-org:service:AVTransport:1"><InstanceID>0</InstanceID></u:GetPositionInfo></s:Body></s:Envelope>`,
+		},
+		{
+			`getTransportInfoSoapBuild Test #1`,
+			getTransportInfoSoapBuild,
+			`<?xml version='1.0' encoding='utf-8'?><s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/" s:encodingStyle="http://schemas.xmlsoap.org/soap/encoding/"><s:Body><u:GetTransportInfo xmlns:u="urn:schemas-upnp-org:service:AVTransport:1"><InstanceID>0</InstanceID></u:GetTransportInfo></s:Body></s:Envelope>`,
+		},
+		{
+			`getMediaInfoSoapBuild Test #1`,
+			getMediaInfoSoapBuild,
+			`<?xml version='1.0' encoding='utf-8'?><s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/" s:encodingStyle="http://schemas.xmlsoap.org/soap/encoding/"><s:Body><u:GetMediaInfo xmlns:u="urn:schemas-upnp-org:service:AVTransport:1"><InstanceID>0</InstanceID></u:GetMediaInfo></s:Body></s:Envelope>`,
+		},
+	}
+
+	for _, tc := range tt {
+		out, err := tc.build()
+		if err != nil {
+			t.Errorf("%s: Failed to build due to %s", tc.name, err.Error())
+			return
+		}
+		if string(out) != tc.want {
+			t.Errorf("%s: got: %s, want: %s.", tc.name, out, tc.want)
+			return
+		}
+	}
+}
@@ -1,8 +1,7 @@
 package soapcalls
 
 import (
-	"bytes"
-	"encoding/xml"
+	"context"
 	"fmt"
 	"net/http"
 	"net/url"
@@ -12,7 +11,7 @@ import (
 	"sync"
 	"time"
 
-	"github.com/hashicorp/go-retryablehttp"
+	"github.com/chyroc/go2tv/utils"
 	"github.com/pkg/errors"
 )
 
@@ -20,103 +19,178 @@ type states struct {
 	previousState string
 	newState      string
 	sequence      int
+	// eventURL is the eventSubURL of the
+	// service the subscription belongs to.
+	eventURL string
 }
 
-var (
-	mediaRenderersStates        = make(map[string]*states)
-	initialMediaRenderersStates = make(map[string]bool)
-	mu                          sync.RWMutex
-)
-
-// TVPayload - this is the heart of Go2TV.
+// TVPayload - this is the heart of Go2TV. Each TVPayload
+// is a session with a single media renderer and keeps its
+// own subscriptions, states and refresh timers, so that
+// multiple media renderers can be driven side by side.
 type TVPayload struct {
-	MediaFile           interface{}
-	CurrentTimers       map[string]*time.Timer
-	ControlURL          string
-	SubtitlesURL        string
-	EventURL            string
-	CallbackURL         string
-	RenderingControlURL string
-	MediaURL            string
-	MediaType           string
-}
-
-// GetMuteRespBody - Build the GetMute response body
-type GetMuteRespBody struct {
-	XMLName       xml.Name `xml:"Envelope"`
-	Text          string   `xml:",chardata"`
-	EncodingStyle string   `xml:"encodingStyle,attr"`
-	S             string   `xml:"s,attr"`
-	Body          struct {
-		Text            string `xml:",chardata"`
-		GetMuteResponse struct {
-			Text        string `xml:",chardata"`
-			U           string `xml:"u,attr"`
-			CurrentMute string `xml:"CurrentMute"`
-		} `xml:"GetMuteResponse"`
-	} `xml:"Body"`
-}
-
-// GetVolumeRespBody - Build the GetVolume response body
-type GetVolumeRespBody struct {
-	XMLName       xml.Name `xml:"Envelope"`
-	Text          string   `xml:",chardata"`
-	EncodingStyle string   `xml:"encodingStyle,attr"`
-	S             string   `xml:"s,attr"`
-	Body          struct {
-		Text              string `xml:",chardata"`
-		GetVolumeResponse struct {
-			Text          string `xml:",chardata"`
-			U             string `xml:"u,attr"`
-			CurrentVolume string `xml:"CurrentVolume"`
-		} `xml:"GetVolumeResponse"`
-	} `xml:"Body"`
-}
-
-func (p *TVPayload) setAVTransportSoapCall() error {
-	parsedURLtransport, err := url.Parse(p.ControlURL)
-	if err != nil {
-		return fmt.Errorf("setAVTransportSoapCall parse error: %w", err)
+	// HTTPClient - Client used for all the calls to the media
+	// renderer. A client with a 10 seconds timeout is used if nil.
+	HTTPClient               *http.Client
+	MediaFile                interface{}
+	CurrentTimers            map[string]*time.Timer
+	ControlURL               string
+	SubtitlesURL             string
+	EventURL                 string
+	CallbackURL              string
+	RenderingControlURL      string
+	RenderingControlEventURL string
+	ConnectionManagerURL     string
+	MediaURL                 string
+	MediaType                string
+	Queue                    MediaQueue
+	nextItem                 *QueueItem
+	queueMu                  sync.RWMutex
+	// mediaRenderersStates and initialMediaRenderersStates
+	// are keyed by the subscription uuid.
+	mediaRenderersStates        map[string]*states
+	initialMediaRenderersStates map[string]bool
+	avTransportState            AVTransportState
+	// sinkProtocolInfo is fetched once per session.
+	sinkProtocolInfo      []string
+	sinkFetched           bool
+	renderingControlState RenderingControlState
+	mu                    sync.RWMutex
+}
+
+// PositionInfo - Current track and playback position
+// as reported by the media renderer.
+type PositionInfo struct {
+	Track         string
+	TrackDuration string
+	TrackURI      string
+	RelTime       string
+	AbsTime       string
+}
+
+// TransportInfo - Transport state and status
+// as reported by the media renderer.
+type TransportInfo struct {
+	CurrentTransportState  string
+	CurrentTransportStatus string
+	CurrentSpeed           string
+}
+
+// MediaInfo - Details of the media currently
+// loaded on the media renderer.
+type MediaInfo struct {
+	NrTracks           int
+	MediaDuration      string
+	CurrentURI         string
+	CurrentURIMetaData string
+	NextURI            string
+	NextURIMetaData    string
+	PlayMedium         string
+}
+
+// AVTransportState - The AVTransport state variables of the
+// session, as merged from the LastChange events received so far.
+type AVTransportState struct {
+	TransportState          string
+	TransportStatus         string
+	CurrentTransportActions string
+	CurrentPlayMode         string
+	NumberOfTracks          int
+	CurrentTrackDuration    string
+	RelativeTimePosition    string
+	CurrentTrackURI         string
+	AVTransportURIMetaData  string
+}
+
+// merge - Apply the variables carried by the event,
+// leaving the ones missing from it untouched.
+func (s *AVTransportState) merge(ev *EventInstance) {
+	set := func(dst *string, v *EventValue) {
+		if v != nil {
+			*dst = v.Value
+		}
 	}
 
-	xml, err := setAVTransportSoapBuild(p.MediaURL, p.MediaType, p.SubtitlesURL)
-	if err != nil {
-		return fmt.Errorf("setAVTransportSoapCall soap build error: %w", err)
+	set(&s.TransportState, ev.TransportState)
+	set(&s.TransportStatus, ev.TransportStatus)
+	set(&s.CurrentTransportActions, ev.CurrentTransportActions)
+	set(&s.CurrentPlayMode, ev.CurrentPlayMode)
+	set(&s.CurrentTrackDuration, ev.CurrentTrackDuration)
+	set(&s.RelativeTimePosition, ev.RelativeTimePosition)
+	set(&s.CurrentTrackURI, ev.CurrentTrackURI)
+	set(&s.AVTransportURIMetaData, ev.AVTransportURIMetaData)
+
+	if ev.NumberOfTracks != nil {
+		if n, err := strconv.Atoi(ev.NumberOfTracks.Value); err == nil {
+			s.NumberOfTracks = n
+		}
 	}
+}
 
-	retryClient := retryablehttp.NewClient()
-	retryClient.RetryMax = 3
-	retryClient.Logger = nil
-	client := retryClient.StandardClient()
+// RenderingControlState - The RenderingControl state variables
+// of the session, as merged from the LastChange events received
+// so far and the volume and mute changes made by us.
+type RenderingControlState struct {
+	Volume int
+	Mute   bool
+}
 
-	req, err := http.NewRequest("POST", parsedURLtransport.String(), bytes.NewReader(xml))
+// merge - Apply the Master channel variables carried by
+// the event, leaving the ones missing from it untouched.
+func (s *RenderingControlState) merge(ev *EventInstance) {
+	for _, v := range ev.Volume {
+		if v.Channel != "" && v.Channel != "Master" {
+			continue
+		}
+		if n, err := strconv.Atoi(v.Value); err == nil {
+			s.Volume = n
+		}
+	}
+
+	for _, v := range ev.Mute {
+		if v.Channel != "" && v.Channel != "Master" {
+			continue
+		}
+		s.Mute = v.Value == "1" || v.Value == "true"
+	}
+}
+
+func (p *TVPayload) setAVTransportSoapCall(ctx context.Context) error {
+	// The queue changes these as it advances.
+	p.queueMu.RLock()
+	item := &QueueItem{
+		MediaURL:     p.MediaURL,
+		MediaType:    p.MediaType,
+		SubtitlesURL: p.SubtitlesURL,
+	}
+	p.queueMu.RUnlock()
+
+	return p.setAVTransportItemSoapCall(ctx, item)
+}
+
+func (p *TVPayload) setAVTransportItemSoapCall(ctx context.Context, item *QueueItem) error {
+	protocolInfo, err := p.protocolInfo(ctx, item.MediaType)
 	if err != nil {
-		return fmt.Errorf("setAVTransportSoapCall POST error: %w", err)
+		return fmt.Errorf("setAVTransportSoapCall error: %w", err)
 	}
 
-	req.Header = http.Header{
-		"SOAPAction":   []string{`"urn:schemas-upnp-org:service:AVTransport:1#SetAVTransportURI"`},
-		"content-type": []string{"text/xml"},
-		"charset":      []string{"utf-8"},
-		"Connection":   []string{"close"},
+	xml, err := setAVTransportSoapBuild(item.MediaURL, item.MediaType, item.SubtitlesURL, protocolInfo)
+	if err != nil {
+		return fmt.Errorf("setAVTransportSoapCall soap build error: %w", err)
 	}
 
-	_, err = client.Do(req)
+	err = soapCall(ctx, newRetryClient(p.client()), p.ControlURL, AVTransportService, "SetAVTransportURI", xml, nil)
 	if err != nil {
-		return fmt.Errorf("setAVTransportSoapCall Do POST error: %w", err)
+		return fmt.Errorf("setAVTransportSoapCall error: %w", err)
 	}
 
 	return nil
 }
 
 // PlayStopSoapCall - Build and call the play soap call.
-func (p *TVPayload) playStopPauseSoapCall(action string) error {
-	parsedURLtransport, err := url.Parse(p.ControlURL)
-	if err != nil {
-		return fmt.Errorf("playStopPauseSoapCall parse error: %w", err)
-	}
-
+func (p *TVPayload) playStopPauseSoapCall(ctx context.Context, action string) error {
 	var xml []byte
+	var err error
 	retry := false
 
 	switch action {
@@ -127,46 +201,211 @@ func (p *TVPayload) playStopPauseSoapCall(action string) error {
 		retry = true
 	case "Pause":
 		xml, err = pauseSoapBuild()
+	default:
+		err = errors.New("unknown action " + action)
 	}
 	if err != nil {
 		return fmt.Errorf("playStopPauseSoapCall action error: %w", err)
 	}
 
-	client := &http.Client{}
+	client := p.client()
 
 	if retry {
-		retryClient := retryablehttp.NewClient()
-		retryClient.RetryMax = 3
-		retryClient.Logger = nil
-		client = retryClient.StandardClient()
+		client = newRetryClient(p.client())
 	}
 
-	req, err := http.NewRequest("POST", parsedURLtransport.String(), bytes.NewReader(xml))
+	if err := soapCall(ctx, client, p.ControlURL, AVTransportService, action, xml, nil); err != nil {
+		return fmt.Errorf("playStopPauseSoapCall error: %w", err)
+	}
+
+	return nil
+}
+
+// GetProtocolInfoSoapCall - Return the protocolInfo list of
+// the media formats the media renderer can play (Sink).
+func (p *TVPayload) GetProtocolInfoSoapCall() ([]string, error) {
+	return p.GetProtocolInfoSoapCallContext(context.Background())
+}
+
+// GetProtocolInfoSoapCallContext - Same as GetProtocolInfoSoapCall, bound to the ctx.
+func (p *TVPayload) GetProtocolInfoSoapCallContext(ctx context.Context) ([]string, error) {
+	xmlbuilder, err := getProtocolInfoSoapBuild()
 	if err != nil {
-		return fmt.Errorf("playStopPauseSoapCall POST error: %w", err)
+		return nil, fmt.Errorf("GetProtocolInfoSoapCall build error: %w", err)
 	}
 
-	req.Header = http.Header{
-		"SOAPAction":   []string{`"urn:schemas-upnp-org:service:AVTransport:1#` + action + `"`},
-		"content-type": []string{"text/xml"},
-		"charset":      []string{"utf-8"},
-		"Connection":   []string{"close"},
+	var out struct {
+		Source string
+		Sink   string
+	}
+	if err := soapCall(ctx, p.client(), p.ConnectionManagerURL, ConnectionManagerService, "GetProtocolInfo", xmlbuilder, &out); err != nil {
+		return nil, fmt.Errorf("GetProtocolInfoSoapCall error: %w", err)
+	}
+
+	sink := make([]string, 0)
+	for _, pi := range strings.Split(out.Sink, ",") {
+		if pi = strings.TrimSpace(pi); pi != "" {
+			sink = append(sink, pi)
+		}
+	}
+
+	return sink, nil
+}
+
+// protocolInfo - Negotiate the protocolInfo for the mediaType
+// with the media renderer. An empty protocolInfo is returned
+// when there is nothing to negotiate with, in which case a
+// generic one is used.
+func (p *TVPayload) protocolInfo(ctx context.Context, mediaType string) (string, error) {
+	if mediaType == "" || p.ConnectionManagerURL == "" {
+		return "", nil
+	}
+
+	p.mu.RLock()
+	sink, fetched := p.sinkProtocolInfo, p.sinkFetched
+	p.mu.RUnlock()
+
+	if !fetched {
+		// Media renderers that fail to report their formats
+		// get the benefit of the doubt.
+		sink, _ = p.GetProtocolInfoSoapCallContext(ctx)
+
+		p.mu.Lock()
+		p.sinkProtocolInfo, p.sinkFetched = sink, true
+		p.mu.Unlock()
 	}
 
-	_, err = client.Do(req)
+	if len(sink) == 0 {
+		return "", nil
+	}
+
+	return utils.MatchProtocolInfo(mediaType, sink)
+}
+
+// SeekSoapCall - Seek to the target position. The unit
+// should be either REL_TIME or ABS_TIME and the target
+// should follow the H+:MM:SS format.
+func (p *TVPayload) SeekSoapCall(unit, target string) error {
+	return p.SeekSoapCallContext(context.Background(), unit, target)
+}
+
+// SeekSoapCallContext - Same as SeekSoapCall, bound to the ctx.
+func (p *TVPayload) SeekSoapCallContext(ctx context.Context, unit, target string) error {
+	xml, err := seekSoapBuild(unit, target)
 	if err != nil {
-		return fmt.Errorf("playStopPauseSoapCall Do POST error: %w", err)
+		return fmt.Errorf("SeekSoapCall build error: %w", err)
+	}
+
+	if err := soapCall(ctx, p.client(), p.ControlURL, AVTransportService, "Seek", xml, nil); err != nil {
+		return fmt.Errorf("SeekSoapCall error: %w", err)
 	}
 
 	return nil
 }
 
-// SubscribeSoapCall - Subscribe to a media renderer
+// GetPositionInfoSoapCall - Return the current track
+// and playback position for target device.
+func (p *TVPayload) GetPositionInfoSoapCall() (*PositionInfo, error) {
+	return p.GetPositionInfoSoapCallContext(context.Background())
+}
+
+// GetPositionInfoSoapCallContext - Same as GetPositionInfoSoapCall, bound to the ctx.
+func (p *TVPayload) GetPositionInfoSoapCallContext(ctx context.Context) (*PositionInfo, error) {
+	xmlbuilder, err := getPositionInfoSoapBuild()
+	if err != nil {
+		return nil, fmt.Errorf("GetPositionInfoSoapCall build error: %w", err)
+	}
+
+	var out PositionInfo
+	if err := soapCall(ctx, p.client(), p.ControlURL, AVTransportService, "GetPositionInfo", xmlbuilder, &out); err != nil {
+		return nil, fmt.Errorf("GetPositionInfoSoapCall error: %w", err)
+	}
+
+	return &out, nil
+}
+
+// GetTransportInfoSoapCall - Return the transport
+// state and status for target device.
+func (p *TVPayload) GetTransportInfoSoapCall() (*TransportInfo, error) {
+	return p.GetTransportInfoSoapCallContext(context.Background())
+}
+
+// GetTransportInfoSoapCallContext - Same as GetTransportInfoSoapCall, bound to the ctx.
+func (p *TVPayload) GetTransportInfoSoapCallContext(ctx context.Context) (*TransportInfo, error) {
+	xmlbuilder, err := getTransportInfoSoapBuild()
+	if err != nil {
+		return nil, fmt.Errorf("GetTransportInfoSoapCall build error: %w", err)
+	}
+
+	var out TransportInfo
+	if err := soapCall(ctx, p.client(), p.ControlURL, AVTransportService, "GetTransportInfo", xmlbuilder, &out); err != nil {
+		return nil, fmt.Errorf("GetTransportInfoSoapCall error: %w", err)
+	}
+
+	return &out, nil
+}
+
+// GetMediaInfoSoapCall - Return details of the media
+// currently loaded on target device.
+func (p *TVPayload) GetMediaInfoSoapCall() (*MediaInfo, error) {
+	return p.GetMediaInfoSoapCallContext(context.Background())
+}
+
+// GetMediaInfoSoapCallContext - Same as GetMediaInfoSoapCall, bound to the ctx.
+func (p *TVPayload) GetMediaInfoSoapCallContext(ctx context.Context) (*MediaInfo, error) {
+	xmlbuilder, err := getMediaInfoSoapBuild()
+	if err != nil {
+		return nil, fmt.Errorf("GetMediaInfoSoapCall build error: %w", err)
+	}
+
+	var out MediaInfo
+	if err := soapCall(ctx, p.client(), p.ControlURL, AVTransportService, "GetMediaInfo", xmlbuilder, &out); err != nil {
+		return nil, fmt.Errorf("GetMediaInfoSoapCall error: %w", err)
+	}
+
+	return &out, nil
+}
+
+// SubscribeSoapCall - Subscribe to the AVTransport events of a media renderer
 // If we explicitly pass the uuid, then we refresh it instead.
 func (p *TVPayload) SubscribeSoapCall(uuidInput string) error {
+	return p.SubscribeSoapCallContext(context.Background(), uuidInput)
+}
+
+// SubscribeSoapCallContext - Same as SubscribeSoapCall, bound to the ctx.
+func (p *TVPayload) SubscribeSoapCallContext(ctx context.Context, uuidInput string) error {
+	p.mu.Lock()
 	delete(p.CurrentTimers, uuidInput)
+	p.mu.Unlock()
+
+	// The subscription was cancelled
+	// while the refresh timer was firing.
+	if uuidInput != "" && !p.hasMRstate(uuidInput) {
+		return nil
+	}
+
+	return p.subscribe(ctx, p.subscriptionURL(uuidInput), uuidInput)
+}
+
+// SubscribeRenderingControlSoapCall - Subscribe to the RenderingControl
+// events of a media renderer, so that we get notified about the volume
+// and mute changes, including the ones made with the remote control.
+func (p *TVPayload) SubscribeRenderingControlSoapCall() error {
+	return p.SubscribeRenderingControlSoapCallContext(context.Background())
+}
 
-	parsedURLcontrol, err := url.Parse(p.EventURL)
+// SubscribeRenderingControlSoapCallContext - Same as
+// SubscribeRenderingControlSoapCall, bound to the ctx.
+func (p *TVPayload) SubscribeRenderingControlSoapCallContext(ctx context.Context) error {
+	if p.RenderingControlEventURL == "" {
+		return errors.New("SubscribeRenderingControlSoapCall: no RenderingControl event URL")
+	}
+
+	return p.subscribe(ctx, p.RenderingControlEventURL, "")
+}
+
+func (p *TVPayload) subscribe(ctx context.Context, eventURL, uuidInput string) error {
+	parsedURLcontrol, err := url.Parse(eventURL)
 	if err != nil {
 		return fmt.Errorf("SubscribeSoapCall #1 parse error: %w", err)
 	}
@@ -176,13 +415,9 @@ func (p *TVPayload) SubscribeSoapCall(uuidInput string) error {
 		return fmt.Errorf("SubscribeSoapCall #2 parse error: %w", err)
 	}
 
-	retryClient := retryablehttp.NewClient()
-	retryClient.RetryMax = 3
-	retryClient.Logger = nil
+	client := newRetryClient(p.client())
 
-	client := retryClient.StandardClient()
-
-	req, err := http.NewRequest("SUBSCRIBE", parsedURLcontrol.String(), nil)
+	req, err := http.NewRequestWithContext(ctx, "SUBSCRIBE", parsedURLcontrol.String(), nil)
 	if err != nil {
 		return fmt.Errorf("SubscribeSoapCall SUBSCRIBE error: %w", err)
 	}
@@ -221,7 +456,7 @@ func (p *TVPayload) SubscribeSoapCall(uuidInput string) error {
 			// we clean up any remaining states for the specific
 			// uuid. The actual UNSUBSCRIBE request to the media
 			// renderer may still fail with error 412, but it's fine.
-			p.UnsubscribeSoapCall(uuid)
+			p.UnsubscribeSoapCallContext(ctx, uuidInput)
 		}
 		return nil
 	}
@@ -239,7 +474,7 @@ func (p *TVPayload) SubscribeSoapCall(uuidInput string) error {
 	// We don't really need to initialize or set
 	// the State if we're just refreshing the uuid.
 	if uuidInput == "" {
-		CreateMRstate(uuid)
+		p.createMRstate(uuid, eventURL)
 	}
 
 	timeoutReply := "300"
@@ -255,16 +490,32 @@ func (p *TVPayload) SubscribeSoapCall(uuidInput string) error {
 // UnsubscribeSoapCall - exported that as we use
 // it for the callback stuff in the httphandlers package.
 func (p *TVPayload) UnsubscribeSoapCall(uuid string) error {
-	DeleteMRstate(uuid)
+	return p.UnsubscribeSoapCallContext(context.Background(), uuid)
+}
+
+// UnsubscribeSoapCallContext - Same as UnsubscribeSoapCall, bound to the ctx.
+func (p *TVPayload) UnsubscribeSoapCallContext(ctx context.Context, uuid string) error {
+	eventURL := p.subscriptionURL(uuid)
+	p.DeleteMRstate(uuid)
 
-	parsedURLcontrol, err := url.Parse(p.EventURL)
+	// Clear the refresh timer to avoid errors responses
+	// from the media renderers. If we don't clear it, we
+	// might receive a "412 Precondition Failed" error.
+	p.mu.Lock()
+	if timer, ok := p.CurrentTimers[uuid]; ok {
+		timer.Stop()
+		delete(p.CurrentTimers, uuid)
+	}
+	p.mu.Unlock()
+
+	parsedURLcontrol, err := url.Parse(eventURL)
 	if err != nil {
 		return fmt.Errorf("UnsubscribeSoapCall parse error: %w", err)
 	}
 
-	client := &http.Client{}
+	client := p.client()
 
-	req, err := http.NewRequest("UNSUBSCRIBE", parsedURLcontrol.String(), nil)
+	req, err := http.NewRequestWithContext(ctx, "UNSUBSCRIBE", parsedURLcontrol.String(), nil)
 	if err != nil {
 		return fmt.Errorf("UnsubscribeSoapCall UNSUBSCRIBE error: %w", err)
 	}
@@ -276,14 +527,50 @@ func (p *TVPayload) UnsubscribeSoapCall(uuid string) error {
 
 	req.Header.Del("User-Agent")
 
-	_, err = client.Do(req)
+	resp, err := client.Do(req)
 	if err != nil {
 		return fmt.Errorf("UnsubscribeSoapCall Do UNSUBSCRIBE error: %w", err)
 	}
+	resp.Body.Close()
 
 	return nil
 }
 
+// UnsubscribeAllSoapCall - Cancel all the subscriptions of
+// the session, AVTransport and RenderingControl alike, along
+// with their refresh timers.
+func (p *TVPayload) UnsubscribeAllSoapCall() error {
+	return p.UnsubscribeAllSoapCallContext(context.Background())
+}
+
+// UnsubscribeAllSoapCallContext - Same as UnsubscribeAllSoapCall, bound to the ctx.
+func (p *TVPayload) UnsubscribeAllSoapCallContext(ctx context.Context) error {
+	p.mu.RLock()
+	localStates := make([]string, 0, len(p.mediaRenderersStates))
+	for uuid := range p.mediaRenderersStates {
+		localStates = append(localStates, uuid)
+	}
+	p.mu.RUnlock()
+
+	// We keep going on errors, so that all the
+	// states and timers get cleaned up anyway.
+	var firstErr error
+	for _, uuid := range localStates {
+		if err := p.UnsubscribeSoapCallContext(ctx, uuid); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	p.mu.Lock()
+	for uuid, timer := range p.CurrentTimers {
+		timer.Stop()
+		delete(p.CurrentTimers, uuid)
+	}
+	p.mu.Unlock()
+
+	return firstErr
+}
+
 // RefreshLoopUUIDSoapCall - Refresh the UUID.
 func (p *TVPayload) RefreshLoopUUIDSoapCall(uuid, timeout string) error {
 	triggerTime := 5
@@ -302,7 +589,12 @@ func (p *TVPayload) RefreshLoopUUIDSoapCall(uuid, timeout string) error {
 	// function arguments.
 	f := p.refreshLoopUUIDAsyncSoapCall(uuid)
 	timer := time.AfterFunc(triggerTimefunc, f)
+	p.mu.Lock()
+	if p.CurrentTimers == nil {
+		p.CurrentTimers = make(map[string]*time.Timer)
+	}
 	p.CurrentTimers[uuid] = timer
+	p.mu.Unlock()
 
 	return nil
 }
@@ -315,121 +607,69 @@ func (p *TVPayload) refreshLoopUUIDAsyncSoapCall(uuid string) func() {
 
 // GetMuteSoapCall - Return mute status for target device
 func (p *TVPayload) GetMuteSoapCall() (string, error) {
-	parsedRenderingControlURL, err := url.Parse(p.RenderingControlURL)
-	if err != nil {
-		return "", fmt.Errorf("GetMuteSoapCall parse error: %w", err)
-	}
-
-	var xmlbuilder []byte
+	return p.GetMuteSoapCallContext(context.Background())
+}
 
-	xmlbuilder, err = getMuteSoapBuild()
+// GetMuteSoapCallContext - Same as GetMuteSoapCall, bound to the ctx.
+func (p *TVPayload) GetMuteSoapCallContext(ctx context.Context) (string, error) {
+	xmlbuilder, err := getMuteSoapBuild()
 	if err != nil {
 		return "", fmt.Errorf("GetMuteSoapCall build error: %w", err)
 	}
 
-	client := &http.Client{}
-	req, err := http.NewRequest("POST", parsedRenderingControlURL.String(), bytes.NewReader(xmlbuilder))
-	if err != nil {
-		return "", fmt.Errorf("GetMuteSoapCall POST error: %w", err)
+	var out struct {
+		CurrentMute string
 	}
-
-	req.Header = http.Header{
-		"SOAPAction":   []string{`"urn:schemas-upnp-org:service:RenderingControl:1#GetMute"`},
-		"content-type": []string{"text/xml"},
-		"charset":      []string{"utf-8"},
-		"Connection":   []string{"close"},
+	if err := soapCall(ctx, p.client(), p.RenderingControlURL, RenderingControlService, "GetMute", xmlbuilder, &out); err != nil {
+		return "", fmt.Errorf("GetMuteSoapCall error: %w", err)
 	}
 
-	resp, err := client.Do(req)
-	if err != nil {
-		return "", fmt.Errorf("GetMuteSoapCall Do POST error: %w", err)
-	}
-
-	defer resp.Body.Close()
-
-	var respGetMute GetMuteRespBody
-	if err = xml.NewDecoder(resp.Body).Decode(&respGetMute); err != nil {
-		return "", fmt.Errorf("GetMuteSoapCall XML Decode error: %w", err)
-	}
-
-	return respGetMute.Body.GetMuteResponse.CurrentMute, nil
+	return out.CurrentMute, nil
 }
 
 // SetMuteSoapCall - Return true if muted and false if not muted/
 func (p *TVPayload) SetMuteSoapCall(number string) error {
-	parsedRenderingControlURL, err := url.Parse(p.RenderingControlURL)
-	if err != nil {
-		return fmt.Errorf("SetMuteSoapCall parse error: %w", err)
-	}
-
-	var xmlbuilder []byte
+	return p.SetMuteSoapCallContext(context.Background(), number)
+}
 
-	xmlbuilder, err = setMuteSoapBuild(number)
+// SetMuteSoapCallContext - Same as SetMuteSoapCall, bound to the ctx.
+func (p *TVPayload) SetMuteSoapCallContext(ctx context.Context, number string) error {
+	xmlbuilder, err := setMuteSoapBuild(number)
 	if err != nil {
 		return fmt.Errorf("SetMuteSoapCall build error: %w", err)
 	}
 
-	client := &http.Client{}
-	req, err := http.NewRequest("POST", parsedRenderingControlURL.String(), bytes.NewReader(xmlbuilder))
-	if err != nil {
-		return fmt.Errorf("SetMuteSoapCall POST error: %w", err)
-	}
-
-	req.Header = http.Header{
-		"SOAPAction":   []string{`"urn:schemas-upnp-org:service:RenderingControl:1#SetMute"`},
-		"content-type": []string{"text/xml"},
-		"charset":      []string{"utf-8"},
-		"Connection":   []string{"close"},
+	if err := soapCall(ctx, p.client(), p.RenderingControlURL, RenderingControlService, "SetMute", xmlbuilder, nil); err != nil {
+		return fmt.Errorf("SetMuteSoapCall error: %w", err)
 	}
 
-	_, err = client.Do(req)
-	if err != nil {
-		return fmt.Errorf("SetMuteSoapCall Do POST error: %w", err)
-	}
+	p.mu.Lock()
+	p.renderingControlState.Mute = number == "1"
+	p.mu.Unlock()
 
 	return nil
 }
 
 // GetVolumeSoapCall - Return volume levels for target device
 func (p *TVPayload) GetVolumeSoapCall() (int, error) {
-	parsedRenderingControlURL, err := url.Parse(p.RenderingControlURL)
-	if err != nil {
-		return 0, fmt.Errorf("GetVolumeSoapCall parse error: %w", err)
-	}
-
-	var xmlbuilder []byte
+	return p.GetVolumeSoapCallContext(context.Background())
+}
 
-	xmlbuilder, err = getVolumeSoapBuild()
+// GetVolumeSoapCallContext - Same as GetVolumeSoapCall, bound to the ctx.
+func (p *TVPayload) GetVolumeSoapCallContext(ctx context.Context) (int, error) {
+	xmlbuilder, err := getVolumeSoapBuild()
 	if err != nil {
 		return 0, fmt.Errorf("GetVolumeSoapCall build error: %w", err)
 	}
 
-	client := &http.Client{}
-	req, err := http.NewRequest("POST", parsedRenderingControlURL.String(), bytes.NewReader(xmlbuilder))
-	if err != nil {
-		return 0, fmt.Errorf("GetVolumeSoapCall POST error: %w", err)
-	}
-
-	req.Header = http.Header{
-		"SOAPAction":   []string{`"urn:schemas-upnp-org:service:RenderingControl:1#GetVolume"`},
-		"content-type": []string{"text/xml"},
-		"charset":      []string{"utf-8"},
-		"Connection":   []string{"close"},
+	var out struct {
+		CurrentVolume string
 	}
-
-	resp, err := client.Do(req)
-	if err != nil {
-		return 0, fmt.Errorf("GetVolumeSoapCall Do POST error: %w", err)
+	if err := soapCall(ctx, p.client(), p.RenderingControlURL, RenderingControlService, "GetVolume", xmlbuilder, &out); err != nil {
+		return 0, fmt.Errorf("GetVolumeSoapCall error: %w", err)
 	}
 
-	defer resp.Body.Close()
-
-	var respGetVolume GetVolumeRespBody
-	if err = xml.NewDecoder(resp.Body).Decode(&respGetVolume); err != nil {
-		return 0, fmt.Errorf("GetVolumeSoapCall XML Decode error: %w", err)
-	}
-
-	intVolume, err := strconv.Atoi(respGetVolume.Body.GetVolumeResponse.CurrentVolume)
+	intVolume, err := strconv.Atoi(out.CurrentVolume)
 	if err != nil {
 		return 0, fmt.Errorf("GetVolumeSoapCall failed to parse volume value: %w", err)
 	}
@@ -443,34 +683,24 @@ func (p *TVPayload) GetVolumeSoapCall() (int, error) {
 
 // SetVolumeSoapCall - Set the desired volume levels
 func (p *TVPayload) SetVolumeSoapCall(v string) error {
-	parsedRenderingControlURL, err := url.Parse(p.RenderingControlURL)
-	if err != nil {
-		return fmt.Errorf("SetMuteSoapCall parse error: %w", err)
-	}
-
-	var xmlbuilder []byte
-
-	xmlbuilder, err = setVolumeSoapBuild(v)
-	if err != nil {
-		return fmt.Errorf("SetMuteSoapCall build error: %w", err)
-	}
+	return p.SetVolumeSoapCallContext(context.Background(), v)
+}
 
-	client := &http.Client{}
-	req, err := http.NewRequest("POST", parsedRenderingControlURL.String(), bytes.NewReader(xmlbuilder))
+// SetVolumeSoapCallContext - Same as SetVolumeSoapCall, bound to the ctx.
+func (p *TVPayload) SetVolumeSoapCallContext(ctx context.Context, v string) error {
+	xmlbuilder, err := setVolumeSoapBuild(v)
 	if err != nil {
-		return fmt.Errorf("SetMuteSoapCall POST error: %w", err)
+		return fmt.Errorf("SetVolumeSoapCall build error: %w", err)
 	}
 
-	req.Header = http.Header{
-		"SOAPAction":   []string{`"urn:schemas-upnp-org:service:RenderingControl:1#SetVolume"`},
-		"content-type": []string{"text/xml"},
-		"charset":      []string{"utf-8"},
-		"Connection":   []string{"close"},
+	if err := soapCall(ctx, p.client(), p.RenderingControlURL, RenderingControlService, "SetVolume", xmlbuilder, nil); err != nil {
+		return fmt.Errorf("SetVolumeSoapCall error: %w", err)
 	}
 
-	_, err = client.Do(req)
-	if err != nil {
-		return fmt.Errorf("SetMuteSoapCall Do POST error: %w", err)
+	if n, err := strconv.Atoi(v); err == nil {
+		p.mu.Lock()
+		p.renderingControlState.Volume = n
+		p.mu.Unlock()
 	}
 
 	return nil
@@ -478,105 +708,181 @@ func (p *TVPayload) SetVolumeSoapCall(v string) error {
 
 // SendtoTV - Send to TV.
 func (p *TVPayload) SendtoTV(action string) error {
+	return p.SendtoTVContext(context.Background(), action)
+}
+
+// SendtoTVContext - Same as SendtoTV, bound to the ctx.
+func (p *TVPayload) SendtoTVContext(ctx context.Context, action string) error {
+	preload := false
 	if action == "Play1" {
-		if err := p.SubscribeSoapCall(""); err != nil {
+		// Fail before subscribing if the media
+		// renderer can't play the media anyway.
+		if _, err := p.protocolInfo(ctx, p.MediaType); err != nil {
+			return fmt.Errorf("SendtoTV error: %w", err)
+		}
+		if err := p.SubscribeSoapCallContext(ctx, ""); err != nil {
 			return fmt.Errorf("SendtoTV subscribe call error: %w", err)
 		}
-		if err := p.setAVTransportSoapCall(); err != nil {
+		// Not all media renderers support RenderingControl
+		// events, we can live without those.
+		if p.RenderingControlEventURL != "" {
+			p.SubscribeRenderingControlSoapCallContext(ctx)
+		}
+		if err := p.setAVTransportSoapCall(ctx); err != nil {
 			return fmt.Errorf("SendtoTV set AVT Transport error: %w", err)
 		}
 		action = "Play"
+		preload = true
 	}
 
 	if action == "Stop" {
-
-		mu.RLock()
-		localStates := make(map[string]*states)
-		for key, value := range mediaRenderersStates {
-			localStates[key] = value
-		}
-		mu.RUnlock()
-
-		// Cleaning up all uuids on force stop.
-		for uuids := range localStates {
-			if err := p.UnsubscribeSoapCall(uuids); err != nil {
-				return fmt.Errorf("SendtoTV unsubscribe call error: %w", err)
-			}
-		}
-
-		// Clear timers on Stop to avoid errors responses
-		// from the media renderers. If we don't clear those, we
-		// might receive a "412 Precondition Failed" error.
-		for uuid, timer := range p.CurrentTimers {
-			timer.Stop()
-			delete(p.CurrentTimers, uuid)
+		if err := p.UnsubscribeAllSoapCallContext(ctx); err != nil {
+			return fmt.Errorf("SendtoTV unsubscribe call error: %w", err)
 		}
 	}
-	err := p.playStopPauseSoapCall(action)
+	err := p.playStopPauseSoapCall(ctx, action)
 	if err != nil {
 		return fmt.Errorf("SendtoTV Play/Stop/Pause action error: %w", err)
 	}
 
+	// A failed preload is not fatal. The pending item will
+	// be cast with SetAVTransportURI once the current one stops.
+	if preload {
+		p.PreloadNextSoapCallContext(ctx)
+	}
+
 	return nil
 }
 
 // UpdateMRstate - Update the mediaRenderersStates map
 // with the state. Return true or false to verify that
 // the actual update took place.
-func UpdateMRstate(previous, new, uuid string) bool {
-	mu.Lock()
-	defer mu.Unlock()
+func (p *TVPayload) UpdateMRstate(previous, new, uuid string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
 	// If the uuid is not one of the UUIDs we stored in
-	// soapcalls.InitialMediaRenderersStates it means that
+	// initialMediaRenderersStates it means that
 	// probably it expired and there is not much we can do
 	// with it. Trying to send an unsubscribe for those will
 	// probably result in a 412 error as per the upnpn documentation
 	// http://upnp.org/specs/arch/UPnP-arch-DeviceArchitecture-v1.1.pdf
 	// (page 94).
-	if initialMediaRenderersStates[uuid] {
-		mediaRenderersStates[uuid].previousState = previous
-		mediaRenderersStates[uuid].newState = new
-		mediaRenderersStates[uuid].sequence++
+	if p.initialMediaRenderersStates[uuid] {
+		p.mediaRenderersStates[uuid].previousState = previous
+		p.mediaRenderersStates[uuid].newState = new
+		p.mediaRenderersStates[uuid].sequence++
 		return true
 	}
 
 	return false
 }
 
+// UpdateLastChange - Merge the LastChange event into the
+// session state. If the event carries a TransportState, the
+// mediaRenderersStates map is updated as well, keeping the
+// current state as the previous one. Return true or false
+// to verify that the actual update took place.
+func (p *TVPayload) UpdateLastChange(ev *EventInstance, uuid string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	// Same as in UpdateMRstate, events from unknown
+	// subscriptions are ignored.
+	if !p.initialMediaRenderersStates[uuid] {
+		return false
+	}
+
+	p.avTransportState.merge(ev)
+	p.renderingControlState.merge(ev)
+
+	st := p.mediaRenderersStates[uuid]
+	if ev.TransportState != nil {
+		st.previousState = st.newState
+		st.newState = ev.TransportState.Value
+	}
+	st.sequence++
+
+	return true
+}
+
+// GetRenderingControlState - Return a snapshot of the
+// RenderingControl state variables of the session.
+func (p *TVPayload) GetRenderingControlState() RenderingControlState {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.renderingControlState
+}
+
+// GetAVTransportState - Return a snapshot of the
+// AVTransport state variables of the session.
+func (p *TVPayload) GetAVTransportState() AVTransportState {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.avTransportState
+}
+
 // CreateMRstate .
-func CreateMRstate(uuid string) {
-	mu.Lock()
-	defer mu.Unlock()
-	initialMediaRenderersStates[uuid] = true
-	mediaRenderersStates[uuid] = &states{
+func (p *TVPayload) CreateMRstate(uuid string) {
+	p.createMRstate(uuid, p.EventURL)
+}
+
+func (p *TVPayload) createMRstate(uuid, eventURL string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.initialMediaRenderersStates == nil {
+		p.initialMediaRenderersStates = make(map[string]bool)
+		p.mediaRenderersStates = make(map[string]*states)
+	}
+	p.initialMediaRenderersStates[uuid] = true
+	p.mediaRenderersStates[uuid] = &states{
 		previousState: "",
 		newState:      "",
 		sequence:      0,
+		eventURL:      eventURL,
 	}
 }
 
 // DeleteMRstate .
-func DeleteMRstate(uuid string) {
-	mu.Lock()
-	defer mu.Unlock()
-	delete(initialMediaRenderersStates, uuid)
-	delete(mediaRenderersStates, uuid)
+func (p *TVPayload) DeleteMRstate(uuid string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.initialMediaRenderersStates, uuid)
+	delete(p.mediaRenderersStates, uuid)
 }
 
 // IncreaseSequence .
-func IncreaseSequence(uuid string) {
-	mu.Lock()
-	defer mu.Unlock()
-	mediaRenderersStates[uuid].sequence++
+func (p *TVPayload) IncreaseSequence(uuid string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.initialMediaRenderersStates[uuid] {
+		p.mediaRenderersStates[uuid].sequence++
+	}
 }
 
 // GetSequence .
-func GetSequence(uuid string) (int, error) {
-	mu.RLock()
-	defer mu.RUnlock()
-	if initialMediaRenderersStates[uuid] {
-		return mediaRenderersStates[uuid].sequence, nil
+func (p *TVPayload) GetSequence(uuid string) (int, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.initialMediaRenderersStates[uuid] {
+		return p.mediaRenderersStates[uuid].sequence, nil
 	}
 
 	return -1, errors.New("zombie callbacks, we should ignore those")
 }
+
+func (p *TVPayload) hasMRstate(uuid string) bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.initialMediaRenderersStates[uuid]
+}
+
+// subscriptionURL - Return the eventSubURL of the service the
+// uuid is subscribed to. Defaults to the AVTransport one.
+func (p *TVPayload) subscriptionURL(uuid string) string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if st, ok := p.mediaRenderersStates[uuid]; ok && st.eventURL != "" {
+		return st.eventURL
+	}
+
+	return p.EventURL
+}
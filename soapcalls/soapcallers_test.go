@@ -0,0 +1,186 @@
+package soapcalls
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMRstatesPerSession(t *testing.T) {
+	tv1 := &TVPayload{}
+	tv2 := &TVPayload{}
+
+	tv1.CreateMRstate("uuid-1")
+	tv2.CreateMRstate("uuid-2")
+
+	if _, err := tv2.GetSequence("uuid-1"); err == nil {
+		t.Errorf("GetSequence: uuid-1 should not be visible to the second session")
+	}
+
+	if !tv1.UpdateMRstate("", "PLAYING", "uuid-1") {
+		t.Errorf("UpdateMRstate: failed to update uuid-1 on the first session")
+	}
+
+	if tv2.UpdateMRstate("", "PLAYING", "uuid-1") {
+		t.Errorf("UpdateMRstate: uuid-1 updated on the second session")
+	}
+
+	tv1.DeleteMRstate("uuid-1")
+
+	seq, err := tv2.GetSequence("uuid-2")
+	if err != nil || seq != 0 {
+		t.Errorf("GetSequence: got: %d %v, want: 0 <nil>.", seq, err)
+	}
+}
+
+func TestUpdateLastChange(t *testing.T) {
+	tv := &TVPayload{}
+	tv.CreateMRstate("uuid-1")
+
+	first := `<e:propertyset xmlns:e="urn:schemas-upnp-org:event-1-0"><e:property><LastChange>` +
+		`<Event xmlns="urn:schemas-upnp-org:metadata-1-0/AVT/"><InstanceID val="0">` +
+		`<TransportState val="PLAYING"/><TransportStatus val="OK"/>` +
+		`<CurrentTrackURI val="http://192.168.88.250:3500/video.mp4"/>` +
+		`<CurrentTrackDuration val="0:42:00"/><NumberOfTracks val="1"/>` +
+		`<AVTransportURIMetaData val="&lt;DIDL-Lite/&gt;"/>` +
+		`</InstanceID></Event></LastChange></e:property></e:propertyset>`
+
+	second := `<e:propertyset xmlns:e="urn:schemas-upnp-org:event-1-0"><e:property><LastChange>` +
+		`<Event xmlns="urn:schemas-upnp-org:metadata-1-0/AVT/"><InstanceID val="0">` +
+		`<RelativeTimePosition val="0:01:30"/><CurrentPlayMode val="NORMAL"/>` +
+		`</InstanceID></Event></LastChange></e:property></e:propertyset>`
+
+	for _, body := range []string{first, second} {
+		ev, err := EventNotifyParser(body)
+		if err != nil {
+			t.Fatalf("EventNotifyParser: %v", err)
+		}
+
+		if !tv.UpdateLastChange(ev, "uuid-1") {
+			t.Fatalf("UpdateLastChange: failed to update uuid-1")
+		}
+	}
+
+	want := AVTransportState{
+		TransportState:         "PLAYING",
+		TransportStatus:        "OK",
+		CurrentPlayMode:        "NORMAL",
+		NumberOfTracks:         1,
+		CurrentTrackDuration:   "0:42:00",
+		RelativeTimePosition:   "0:01:30",
+		CurrentTrackURI:        "http://192.168.88.250:3500/video.mp4",
+		AVTransportURIMetaData: "<DIDL-Lite/>",
+	}
+
+	if got := tv.GetAVTransportState(); got != want {
+		t.Errorf("GetAVTransportState: got: %+v, want: %+v.", got, want)
+	}
+
+	if seq, _ := tv.GetSequence("uuid-1"); seq != 2 {
+		t.Errorf("GetSequence: got: %d, want: 2.", seq)
+	}
+}
+
+func TestUpdateLastChangeRenderingControl(t *testing.T) {
+	tv := &TVPayload{}
+	tv.CreateMRstate("uuid-rc")
+
+	body := `<e:propertyset xmlns:e="urn:schemas-upnp-org:event-1-0"><e:property><LastChange>` +
+		`<Event xmlns="urn:schemas-upnp-org:metadata-1-0/RCS/"><InstanceID val="0">` +
+		`<Volume channel="Master" val="23"/><Volume channel="LF" val="50"/>` +
+		`<Mute channel="Master" val="1"/>` +
+		`</InstanceID></Event></LastChange></e:property></e:propertyset>`
+
+	ev, err := EventNotifyParser(body)
+	if err != nil {
+		t.Fatalf("EventNotifyParser: %v", err)
+	}
+
+	if !tv.UpdateLastChange(ev, "uuid-rc") {
+		t.Fatalf("UpdateLastChange: failed to update uuid-rc")
+	}
+
+	want := RenderingControlState{Volume: 23, Mute: true}
+	if got := tv.GetRenderingControlState(); got != want {
+		t.Errorf("GetRenderingControlState: got: %+v, want: %+v.", got, want)
+	}
+
+	if got := tv.GetAVTransportState(); got != (AVTransportState{}) {
+		t.Errorf("GetAVTransportState: got: %+v, want an empty state.", got)
+	}
+}
+
+func TestGetInfoSoapCalls(t *testing.T) {
+	responses := map[string]string{
+		"GetPositionInfo": `<Track>1</Track><TrackDuration>0:42:00</TrackDuration>` +
+			`<TrackMetaData>NOT_IMPLEMENTED</TrackMetaData><TrackURI>http://192.168.88.250:3500/video.mp4</TrackURI>` +
+			`<RelTime>0:01:30</RelTime><AbsTime>0:01:30</AbsTime><RelCount>2147483647</RelCount><AbsCount>2147483647</AbsCount>`,
+		"GetTransportInfo": `<CurrentTransportState>PLAYING</CurrentTransportState>` +
+			`<CurrentTransportStatus>OK</CurrentTransportStatus><CurrentSpeed>1</CurrentSpeed>`,
+		"GetMediaInfo": `<NrTracks>1</NrTracks><MediaDuration>0:42:00</MediaDuration>` +
+			`<CurrentURI>http://192.168.88.250:3500/video.mp4</CurrentURI><CurrentURIMetaData>&lt;DIDL-Lite/&gt;</CurrentURIMetaData>` +
+			`<NextURI></NextURI><NextURIMetaData></NextURIMetaData><PlayMedium>NETWORK</PlayMedium>` +
+			`<RecordMedium>NOT_IMPLEMENTED</RecordMedium><WriteStatus>NOT_IMPLEMENTED</WriteStatus>`,
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		action := r.Header.Get("SOAPAction")
+		action = strings.Trim(action[strings.Index(action, "#")+1:], `"`)
+
+		w.Header().Set("Content-Type", `text/xml; charset="utf-8"`)
+		io.WriteString(w, `<?xml version="1.0"?><s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/" s:encodingStyle="http://schemas.xmlsoap.org/soap/encoding/"><s:Body>`+
+			`<u:`+action+`Response xmlns:u="urn:schemas-upnp-org:service:AVTransport:1">`+responses[action]+
+			`</u:`+action+`Response></s:Body></s:Envelope>`)
+	}))
+	defer srv.Close()
+
+	tv := &TVPayload{ControlURL: srv.URL}
+
+	pos, err := tv.GetPositionInfoSoapCall()
+	if err != nil {
+		t.Fatalf("GetPositionInfoSoapCall: %v", err)
+	}
+
+	wantPos := PositionInfo{
+		Track:         "1",
+		TrackDuration: "0:42:00",
+		TrackURI:      "http://192.168.88.250:3500/video.mp4",
+		RelTime:       "0:01:30",
+		AbsTime:       "0:01:30",
+	}
+	if *pos != wantPos {
+		t.Errorf("GetPositionInfoSoapCall: got: %+v, want: %+v.", *pos, wantPos)
+	}
+
+	info, err := tv.GetTransportInfoSoapCall()
+	if err != nil {
+		t.Fatalf("GetTransportInfoSoapCall: %v", err)
+	}
+
+	wantInfo := TransportInfo{
+		CurrentTransportState:  "PLAYING",
+		CurrentTransportStatus: "OK",
+		CurrentSpeed:           "1",
+	}
+	if *info != wantInfo {
+		t.Errorf("GetTransportInfoSoapCall: got: %+v, want: %+v.", *info, wantInfo)
+	}
+
+	media, err := tv.GetMediaInfoSoapCall()
+	if err != nil {
+		t.Fatalf("GetMediaInfoSoapCall: %v", err)
+	}
+
+	wantMedia := MediaInfo{
+		NrTracks:           1,
+		MediaDuration:      "0:42:00",
+		CurrentURI:         "http://192.168.88.250:3500/video.mp4",
+		CurrentURIMetaData: "<DIDL-Lite/>",
+		PlayMedium:         "NETWORK",
+	}
+	if *media != wantMedia {
+		t.Errorf("GetMediaInfoSoapCall: got: %+v, want: %+v.", *media, wantMedia)
+	}
+}
@@ -0,0 +1,102 @@
+package soapcalls
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// UPnP error codes, as defined in the UPnP Device Architecture
+// and the AVTransport service specification.
+const (
+	ErrCodeInvalidAction          = 401
+	ErrCodeInvalidArgs            = 402
+	ErrCodeActionFailed           = 501
+	ErrCodeTransitionNotAvailable = 701
+	ErrCodeNoContents             = 702
+	ErrCodeReadError              = 703
+	ErrCodeFormatNotSupported     = 704
+	ErrCodeTransportLocked        = 705
+	ErrCodeSeekModeNotSupported   = 710
+	ErrCodeIllegalSeekTarget      = 711
+	ErrCodePlayModeNotSupported   = 712
+	ErrCodeIllegalMIMEType        = 714
+	ErrCodeContentBusy            = 715
+	ErrCodeResourceNotFound       = 716
+	ErrCodePlaySpeedNotSupported  = 717
+	ErrCodeInvalidInstanceID      = 718
+)
+
+var upnpErrorDescriptions = map[int]string{
+	ErrCodeInvalidAction:          "Invalid Action",
+	ErrCodeInvalidArgs:            "Invalid Args",
+	ErrCodeActionFailed:           "Action Failed",
+	ErrCodeTransitionNotAvailable: "Transition not available",
+	ErrCodeNoContents:             "No contents",
+	ErrCodeReadError:              "Read error",
+	ErrCodeFormatNotSupported:     "Format not supported for playback",
+	ErrCodeTransportLocked:        "Transport is locked",
+	ErrCodeSeekModeNotSupported:   "Seek mode not supported",
+	ErrCodeIllegalSeekTarget:      "Illegal seek target",
+	ErrCodePlayModeNotSupported:   "Play mode not supported",
+	ErrCodeIllegalMIMEType:        "Illegal MIME-type",
+	ErrCodeContentBusy:            "Content 'BUSY'",
+	ErrCodeResourceNotFound:       "Resource not found",
+	ErrCodePlaySpeedNotSupported:  "Play speed not supported",
+	ErrCodeInvalidInstanceID:      "Invalid InstanceID",
+}
+
+// UPnPError - The UPnPError details of a SOAP Fault
+// returned by the media renderer.
+type UPnPError struct {
+	Action      string
+	StatusCode  int
+	Code        int
+	Description string
+}
+
+func (e *UPnPError) Error() string {
+	return fmt.Sprintf("%s failed with UPnP error %d: %s", e.Action, e.Code, e.Description)
+}
+
+// soapFault - SOAP Fault envelope node.
+type soapFault struct {
+	XMLName     xml.Name `xml:"Envelope"`
+	FaultCode   string   `xml:"Body>Fault>faultcode"`
+	FaultString string   `xml:"Body>Fault>faultstring"`
+	UPnPError   struct {
+		ErrorCode        int    `xml:"errorCode"`
+		ErrorDescription string `xml:"errorDescription"`
+	} `xml:"Body>Fault>detail>UPnPError"`
+}
+
+// checkSoapResponse - Verify that the media renderer accepted
+// the action. SOAP Faults are returned as *UPnPError.
+func checkSoapResponse(action string, resp *http.Response) error {
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("%s read error: %w", action, err)
+	}
+
+	var fault soapFault
+	if err := xml.Unmarshal(body, &fault); err != nil || fault.UPnPError.ErrorCode == 0 {
+		return fmt.Errorf("%s bad status code: %s", action, resp.Status)
+	}
+
+	description := fault.UPnPError.ErrorDescription
+	if description == "" {
+		description = upnpErrorDescriptions[fault.UPnPError.ErrorCode]
+	}
+
+	return &UPnPError{
+		Action:      action,
+		StatusCode:  resp.StatusCode,
+		Code:        fault.UPnPError.ErrorCode,
+		Description: description,
+	}
+}
@@ -0,0 +1,63 @@
+package soapcalls
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestCheckSoapResponse(t *testing.T) {
+	tt := []struct {
+		name            string
+		statusCode      int
+		body            string
+		wantCode        int
+		wantDescription string
+	}{
+		{
+			`checkSoapResponse Test #1`,
+			http.StatusInternalServerError,
+			`<?xml version="1.0"?><s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/" s:encodingStyle="http://schemas.xmlsoap.org/soap/encoding/"><s:Body><s:Fault><faultcode>s:Client</faultcode><faultstring>UPnPError</faultstring><detail><UPnPError xmlns="urn:schemas-upnp-org:control-1-0"><errorCode>701</errorCode><errorDescription>Transition not available</errorDescription></UPnPError></detail></s:Fault></s:Body></s:Envelope>`,
+			ErrCodeTransitionNotAvailable,
+			"Transition not available",
+		},
+		{
+			`checkSoapResponse Test #2`,
+			http.StatusInternalServerError,
+			`<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/"><s:Body><s:Fault><faultcode>s:Client</faultcode><faultstring>UPnPError</faultstring><detail><UPnPError xmlns="urn:schemas-upnp-org:control-1-0"><errorCode>714</errorCode></UPnPError></detail></s:Fault></s:Body></s:Envelope>`,
+			ErrCodeIllegalMIMEType,
+			"Illegal MIME-type",
+		},
+	}
+
+	for _, tc := range tt {
+		resp := &http.Response{
+			StatusCode: tc.statusCode,
+			Status:     http.StatusText(tc.statusCode),
+			Body:       io.NopCloser(strings.NewReader(tc.body)),
+		}
+
+		err := checkSoapResponse("Play", resp)
+
+		var upnpErr *UPnPError
+		if !errors.As(err, &upnpErr) {
+			t.Errorf("%s: expected *UPnPError, got: %v", tc.name, err)
+			return
+		}
+
+		if upnpErr.Code != tc.wantCode || upnpErr.Description != tc.wantDescription {
+			t.Errorf("%s: got: %d %s, want: %d %s.", tc.name, upnpErr.Code, upnpErr.Description, tc.wantCode, tc.wantDescription)
+			return
+		}
+	}
+
+	ok := &http.Response{
+		StatusCode: http.StatusOK,
+		Body:       io.NopCloser(strings.NewReader("")),
+	}
+	if err := checkSoapResponse("Play", ok); err != nil {
+		t.Errorf("checkSoapResponse: unexpected error for 200 OK: %s", err)
+	}
+}
@@ -1,6 +1,7 @@
 package soapcalls
 
 import (
+	"context"
 	"encoding/xml"
 	"fmt"
 	"io"
@@ -14,13 +15,106 @@ import (
 // Root - root node.
 type Root struct {
 	XMLName xml.Name `xml:"root"`
+	URLBase string   `xml:"URLBase"`
 	Device  Device   `xml:"device"`
 }
 
-// Device - device node (we should only expect one?).
+// Device - device node. The root device may
+// have embedded devices in its deviceList.
 type Device struct {
-	XMLName     xml.Name    `xml:"device"`
-	ServiceList ServiceList `xml:"serviceList"`
+	XMLName      xml.Name    `xml:"device"`
+	DeviceType   string      `xml:"deviceType"`
+	UDN          string      `xml:"UDN"`
+	FriendlyName string      `xml:"friendlyName"`
+	Manufacturer string      `xml:"manufacturer"`
+	ModelName    string      `xml:"modelName"`
+	ModelNumber  string      `xml:"modelNumber"`
+	IconList     []Icon      `xml:"iconList>icon"`
+	ServiceList  ServiceList `xml:"serviceList"`
+	DeviceList   []Device    `xml:"deviceList>device"`
+}
+
+const (
+	mediaRendererDeviceType = "urn:schemas-upnp-org:device:MediaRenderer:"
+	avTransportServiceType  = "urn:schemas-upnp-org:service:AVTransport:"
+	renderingControlType    = "urn:schemas-upnp-org:service:RenderingControl:"
+	connectionManagerType   = "urn:schemas-upnp-org:service:ConnectionManager:"
+)
+
+// MediaRenderer - Return the MediaRenderer device of the device
+// tree. If none is declared as such, the first device with an
+// AVTransport service is returned. Returns nil if there is none.
+func (r *Root) MediaRenderer() *Device {
+	if d := r.Device.find(func(d *Device) bool {
+		return strings.HasPrefix(d.DeviceType, mediaRendererDeviceType)
+	}); d != nil {
+		return d
+	}
+
+	return r.Device.find(func(d *Device) bool {
+		return d.service(avTransportServiceType, "urn:upnp-org:serviceId:AVTransport") != nil
+	})
+}
+
+// ResolveURL - Resolve a URL of the device description as per the
+// UPnP Device Architecture. Relative URLs are relative to the URLBase
+// or, if there is no URLBase, to the location of the description.
+func (r *Root) ResolveURL(location, ref string) (string, error) {
+	base, err := url.Parse(location)
+	if err != nil {
+		return "", fmt.Errorf("ResolveURL location parse error: %w", err)
+	}
+
+	if urlBase := strings.TrimSpace(r.URLBase); urlBase != "" {
+		base, err = base.Parse(urlBase)
+		if err != nil {
+			return "", fmt.Errorf("ResolveURL URLBase parse error: %w", err)
+		}
+	}
+
+	u, err := base.Parse(strings.TrimSpace(ref))
+	if err != nil {
+		return "", fmt.Errorf("ResolveURL parse error: %w", err)
+	}
+
+	return u.String(), nil
+}
+
+// find - Walk the device tree depth-first and
+// return the first device that matches.
+func (d *Device) find(match func(*Device) bool) *Device {
+	if match(d) {
+		return d
+	}
+
+	for i := range d.DeviceList {
+		if found := d.DeviceList[i].find(match); found != nil {
+			return found
+		}
+	}
+
+	return nil
+}
+
+// service - Return the service of the device matching
+// either the service type (any version) or the service ID.
+func (d *Device) service(serviceType, serviceID string) *Service {
+	for i, s := range d.ServiceList.Services {
+		if strings.HasPrefix(strings.TrimSpace(s.Type), serviceType) || strings.TrimSpace(s.ID) == serviceID {
+			return &d.ServiceList.Services[i]
+		}
+	}
+
+	return nil
+}
+
+// Icon - icon node.
+type Icon struct {
+	MIMEType string `xml:"mimetype"`
+	Width    int    `xml:"width"`
+	Height   int    `xml:"height"`
+	Depth    int    `xml:"depth"`
+	URL      string `xml:"url"`
 }
 
 // ServiceList - serviceList node
@@ -44,43 +138,66 @@ type EventPropertySet struct {
 	EventInstance EventInstance `xml:"property>LastChange>Event>InstanceID"`
 }
 
-// EventInstance .
+// EventInstance - The AVTransport and RenderingControl state variables
+// of a LastChange event. Media renderers only send the variables that
+// changed, so the ones missing from the event are nil or empty.
 type EventInstance struct {
-	XMLName                      xml.Name                     `xml:"InstanceID"`
-	Value                        string                       `xml:"val,attr"`
-	EventCurrentTransportActions EventCurrentTransportActions `xml:"CurrentTransportActions"`
-	EventTransportState          EventTransportState          `xml:"TransportState"`
+	XMLName                 xml.Name            `xml:"InstanceID"`
+	Value                   string              `xml:"val,attr"`
+	TransportState          *EventValue         `xml:"TransportState"`
+	TransportStatus         *EventValue         `xml:"TransportStatus"`
+	CurrentTransportActions *EventValue         `xml:"CurrentTransportActions"`
+	CurrentPlayMode         *EventValue         `xml:"CurrentPlayMode"`
+	NumberOfTracks          *EventValue         `xml:"NumberOfTracks"`
+	CurrentTrackDuration    *EventValue         `xml:"CurrentTrackDuration"`
+	RelativeTimePosition    *EventValue         `xml:"RelativeTimePosition"`
+	CurrentTrackURI         *EventValue         `xml:"CurrentTrackURI"`
+	AVTransportURIMetaData  *EventValue         `xml:"AVTransportURIMetaData"`
+	Volume                  []EventChannelValue `xml:"Volume"`
+	Mute                    []EventChannelValue `xml:"Mute"`
 }
 
-// EventCurrentTransportActions .
-type EventCurrentTransportActions struct {
+// EventValue .
+type EventValue struct {
 	Value string `xml:"val,attr"`
 }
 
-// EventTransportState .
-type EventTransportState struct {
-	Value string `xml:"val,attr"`
+// EventChannelValue - RenderingControl state variables
+// are reported per audio channel.
+type EventChannelValue struct {
+	Channel string `xml:"channel,attr"`
+	Value   string `xml:"val,attr"`
 }
 
 // DMRextracted .
 type DMRextracted struct {
-	AvtransportControlURL  string
-	AvtransportEventSubURL string
-	RenderingControlURL    string
+	AvtransportControlURL       string
+	AvtransportEventSubURL      string
+	RenderingControlURL         string
+	RenderingControlEventSubURL string
+	ConnectionManagerURL        string
 }
 
 // DMRextractor - Get the AVTransport URL from the main DMR xml.
 func DMRextractor(dmrurl string) (*DMRextracted, error) {
+	return DMRextractorContext(context.Background(), nil, dmrurl)
+}
+
+// DMRextractorContext - Same as DMRextractor, bound to the ctx.
+// If client is nil, a client with a 10 seconds timeout is used.
+func DMRextractorContext(ctx context.Context, client *http.Client, dmrurl string) (*DMRextracted, error) {
 	var root Root
 	ex := &DMRextracted{}
 
-	parsedURL, err := url.Parse(dmrurl)
-	if err != nil {
+	if _, err := url.Parse(dmrurl); err != nil {
 		return nil, fmt.Errorf("DMRextractor parse error: %w", err)
 	}
 
-	client := &http.Client{}
-	req, err := http.NewRequest("GET", dmrurl, nil)
+	if client == nil {
+		client = defaultHTTPClient()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", dmrurl, nil)
 	if err != nil {
 		return nil, fmt.Errorf("DMRextractor GET error: %w", err)
 	}
@@ -97,23 +214,38 @@ func DMRextractor(dmrurl string) (*DMRextracted, error) {
 	if err != nil {
 		return nil, fmt.Errorf("DMRextractor read error: %w", err)
 	}
-	xml.Unmarshal(xmlbody, &root)
-	for i := 0; i < len(root.Device.ServiceList.Services); i++ {
-		service := root.Device.ServiceList.Services[i]
-		if !strings.HasPrefix(service.EventSubURL, "/") {
-			service.EventSubURL = "/" + service.EventSubURL
-		}
-		if !strings.HasPrefix(service.ControlURL, "/") {
-			service.ControlURL = "/" + service.ControlURL
-		}
+	if err := xml.Unmarshal(xmlbody, &root); err != nil {
+		return nil, fmt.Errorf("DMRextractor unmarshal error: %w", err)
+	}
+
+	dev := root.MediaRenderer()
+	if dev == nil {
+		return nil, errors.New("DMRextractor: no MediaRenderer device found")
+	}
 
-		if service.ID == "urn:upnp-org:serviceId:AVTransport" {
-			ex.AvtransportControlURL = parsedURL.Scheme + "://" + parsedURL.Host + service.ControlURL
-			ex.AvtransportEventSubURL = parsedURL.Scheme + "://" + parsedURL.Host + service.EventSubURL
+	resolve := func(ref string) string {
+		if ref == "" {
+			return ""
 		}
-		if service.ID == "urn:upnp-org:serviceId:RenderingControl" {
-			ex.RenderingControlURL = parsedURL.Scheme + "://" + parsedURL.Host + service.ControlURL
+		u, err := root.ResolveURL(dmrurl, ref)
+		if err != nil {
+			return ""
 		}
+		return u
+	}
+
+	if s := dev.service(avTransportServiceType, "urn:upnp-org:serviceId:AVTransport"); s != nil {
+		ex.AvtransportControlURL = resolve(s.ControlURL)
+		ex.AvtransportEventSubURL = resolve(s.EventSubURL)
+	}
+
+	if s := dev.service(renderingControlType, "urn:upnp-org:serviceId:RenderingControl"); s != nil {
+		ex.RenderingControlURL = resolve(s.ControlURL)
+		ex.RenderingControlEventSubURL = resolve(s.EventSubURL)
+	}
+
+	if s := dev.service(connectionManagerType, "urn:upnp-org:serviceId:ConnectionManager"); s != nil {
+		ex.ConnectionManagerURL = resolve(s.ControlURL)
 	}
 
 	if ex.AvtransportControlURL != "" {
@@ -124,14 +256,12 @@ func DMRextractor(dmrurl string) (*DMRextracted, error) {
 }
 
 // EventNotifyParser - Parse the Notify messages from the media renderer.
-func EventNotifyParser(xmlbody string) (string, string, error) {
+func EventNotifyParser(xmlbody string) (*EventInstance, error) {
 	var root EventPropertySet
 	err := xml.Unmarshal([]byte(xmlbody), &root)
 	if err != nil {
-		return "", "", fmt.Errorf("EventNotifyParser unmarshal error: %w", err)
+		return nil, fmt.Errorf("EventNotifyParser unmarshal error: %w", err)
 	}
-	previousstate := root.EventInstance.EventCurrentTransportActions.Value
-	newstate := root.EventInstance.EventTransportState.Value
 
-	return previousstate, newstate, nil
+	return &root.EventInstance, nil
 }
@@ -0,0 +1,77 @@
+package soapcalls
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDMRextractor(t *testing.T) {
+	tt := []struct {
+		name        string
+		description string
+		want        DMRextracted
+	}{
+		{
+			`Root device, relative to description`,
+			`<root><device><deviceType>urn:schemas-upnp-org:device:MediaRenderer:1</deviceType><serviceList>` +
+				`<service><serviceType>urn:schemas-upnp-org:service:AVTransport:1</serviceType><serviceId>urn:upnp-org:serviceId:AVTransport</serviceId><controlURL>upnp/control/AVTransport1</controlURL><eventSubURL>/upnp/event/AVTransport1</eventSubURL></service>` +
+				`<service><serviceType>urn:schemas-upnp-org:service:RenderingControl:1</serviceType><serviceId>urn:upnp-org:serviceId:RenderingControl</serviceId><controlURL>upnp/control/RenderingControl1</controlURL><eventSubURL>upnp/event/RenderingControl1</eventSubURL></service>` +
+				`</serviceList></device></root>`,
+			DMRextracted{
+				AvtransportControlURL:       "{{host}}/dmr/upnp/control/AVTransport1",
+				AvtransportEventSubURL:      "{{host}}/upnp/event/AVTransport1",
+				RenderingControlURL:         "{{host}}/dmr/upnp/control/RenderingControl1",
+				RenderingControlEventSubURL: "{{host}}/dmr/upnp/event/RenderingControl1",
+			},
+		},
+		{
+			`Embedded device with URLBase`,
+			`<root><URLBase>http://192.168.88.20:1400/</URLBase><device><deviceType>urn:schemas-upnp-org:device:ZonePlayer:1</deviceType>` +
+				`<serviceList><service><serviceType>urn:schemas-upnp-org:service:AVTransport:1</serviceType><controlURL>/wrong</controlURL></service></serviceList>` +
+				`<deviceList><device><deviceType>urn:schemas-upnp-org:device:MediaServer:1</deviceType></device>` +
+				`<device><deviceType>urn:schemas-upnp-org:device:MediaRenderer:1</deviceType><serviceList>` +
+				`<service><serviceType>urn:schemas-upnp-org:service:AVTransport:1</serviceType><serviceId>urn:upnp-org:serviceId:AVTransport</serviceId><controlURL>MediaRenderer/AVTransport/Control</controlURL><eventSubURL>MediaRenderer/AVTransport/Event</eventSubURL></service>` +
+				`<service><serviceType>urn:schemas-upnp-org:service:ConnectionManager:1</serviceType><serviceId>urn:upnp-org:serviceId:ConnectionManager</serviceId><controlURL>http://192.168.88.21/cm</controlURL></service>` +
+				`</serviceList></device></deviceList></device></root>`,
+			DMRextracted{
+				AvtransportControlURL:  "http://192.168.88.20:1400/MediaRenderer/AVTransport/Control",
+				AvtransportEventSubURL: "http://192.168.88.20:1400/MediaRenderer/AVTransport/Event",
+				ConnectionManagerURL:   "http://192.168.88.21/cm",
+			},
+		},
+	}
+
+	for _, tc := range tt {
+		description := tc.description
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			io.WriteString(w, description)
+		}))
+
+		replace := func(s string) string {
+			if len(s) > 8 && s[:8] == "{{host}}" {
+				return srv.URL + s[8:]
+			}
+			return s
+		}
+		want := DMRextracted{
+			AvtransportControlURL:       replace(tc.want.AvtransportControlURL),
+			AvtransportEventSubURL:      replace(tc.want.AvtransportEventSubURL),
+			RenderingControlURL:         replace(tc.want.RenderingControlURL),
+			RenderingControlEventSubURL: replace(tc.want.RenderingControlEventSubURL),
+			ConnectionManagerURL:        replace(tc.want.ConnectionManagerURL),
+		}
+
+		out, err := DMRextractor(srv.URL + "/dmr/description.xml")
+		srv.Close()
+		if err != nil {
+			t.Errorf("%s: Failed to call DMRextractor due to %s", tc.name, err.Error())
+			continue
+		}
+
+		if *out != want {
+			t.Errorf("%s: got: %+v, want: %+v.", tc.name, *out, want)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package urlstreamer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// RangedURL - A media URL whose server supports byte ranges. Instead
+// of streaming it once, every request of the media renderer is
+// forwarded to the server along with its Range header.
+type RangedURL struct {
+	URL    string
+	Client *http.Client
+}
+
+// NewRangedURL - Check that the server of the URL supports
+// byte ranges. Returns an error if it doesn't.
+func NewRangedURL(ctx context.Context, s string) (*RangedURL, error) {
+	_, err := url.ParseRequestURI(s)
+	if err != nil {
+		return nil, fmt.Errorf("newRangedURL failed to parse url: %w", err)
+	}
+
+	u := &RangedURL{
+		URL:    s,
+		Client: &http.Client{},
+	}
+
+	// Some servers ignore ranges on HEAD requests, so we
+	// ask for the first byte and look for a 206 instead.
+	resp, err := u.Do(ctx, http.MethodGet, "bytes=0-0")
+	if err != nil {
+		return nil, fmt.Errorf("newRangedURL error: %w", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusPartialContent {
+		return nil, errors.New("newRangedURL: no byte range support: " + resp.Status)
+	}
+
+	return u, nil
+}
+
+// Do - Send a request to the URL. rangeHeader
+// is forwarded as-is, if not empty.
+func (u *RangedURL) Do(ctx context.Context, method, rangeHeader string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, u.URL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("rangedURL failed to call NewRequest: %w", err)
+	}
+
+	if rangeHeader != "" {
+		req.Header.Set("Range", rangeHeader)
+	}
+
+	client := u.Client
+	if client == nil {
+		client = &http.Client{}
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("rangedURL failed to client.Do: %w", err)
+	}
+
+	return resp, nil
+}
@@ -0,0 +1,47 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ClockTimeToSeconds - Convert the H+:MM:SS[.F+] time format
+// used by the AVTransport service to seconds. Any fraction
+// of a second is dropped.
+func ClockTimeToSeconds(t string) (int, error) {
+	parts := strings.Split(t, ":")
+	if len(parts) != 3 {
+		return 0, errors.New("clockTimeToSeconds: invalid time format")
+	}
+
+	// Drop the fraction of the second, if any.
+	parts[2] = strings.Split(parts[2], ".")[0]
+
+	var seconds int
+	for _, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return 0, fmt.Errorf("clockTimeToSeconds parse error: %w", err)
+		}
+
+		if n < 0 {
+			return 0, errors.New("clockTimeToSeconds: negative time value")
+		}
+
+		seconds = seconds*60 + n
+	}
+
+	return seconds, nil
+}
+
+// SecondsToClockTime - Convert seconds to the H+:MM:SS
+// time format used by the AVTransport service.
+func SecondsToClockTime(s int) (string, error) {
+	if s < 0 {
+		return "", errors.New("secondsToClockTime: negative time value")
+	}
+
+	return fmt.Sprintf("%d:%02d:%02d", s/3600, (s/60)%60, s%60), nil
+}
@@ -0,0 +1,88 @@
+package utils
+
+import (
+	"testing"
+)
+
+func TestClockTimeToSeconds(t *testing.T) {
+	tt := []struct {
+		name  string
+		input string
+		want  int
+	}{
+		{
+			`Test #1`,
+			`0:00:00`,
+			0,
+		},
+		{
+			`Test #2`,
+			`01:02:03`,
+			3723,
+		},
+		{
+			`Test #3`,
+			`0:10:05.500`,
+			605,
+		},
+		{
+			`Test #4`,
+			`100:00:01`,
+			360001,
+		},
+	}
+
+	for _, tc := range tt {
+		out, err := ClockTimeToSeconds(tc.input)
+		if err != nil {
+			t.Errorf("%s: Failed to call ClockTimeToSeconds due to %s", tc.name, err.Error())
+			return
+		}
+		if out != tc.want {
+			t.Errorf("%s: got: %d, want: %d.", tc.name, out, tc.want)
+			return
+		}
+	}
+
+	for _, input := range []string{"NOT_IMPLEMENTED", "", "10:00", "0:-1:00"} {
+		if _, err := ClockTimeToSeconds(input); err == nil {
+			t.Errorf("ClockTimeToSeconds: expected error for input %q", input)
+		}
+	}
+}
+
+func TestSecondsToClockTime(t *testing.T) {
+	tt := []struct {
+		name  string
+		input int
+		want  string
+	}{
+		{
+			`Test #1`,
+			0,
+			`0:00:00`,
+		},
+		{
+			`Test #2`,
+			3723,
+			`1:02:03`,
+		},
+		{
+			`Test #3`,
+			360001,
+			`100:00:01`,
+		},
+	}
+
+	for _, tc := range tt {
+		out, err := SecondsToClockTime(tc.input)
+		if err != nil {
+			t.Errorf("%s: Failed to call SecondsToClockTime due to %s", tc.name, err.Error())
+			return
+		}
+		if out != tc.want {
+			t.Errorf("%s: got: %s, want: %s.", tc.name, out, tc.want)
+			return
+		}
+	}
+}
@@ -44,6 +44,11 @@ var (
 	}
 )
 
+// mediaTypeAliases - Media types that go by more than one name.
+var mediaTypeAliases = map[string]string{
+	"video/x-mkv": "video/x-matroska",
+}
+
 func defaultStreamingFlags() string {
 	return fmt.Sprintf("%.8x%.24x", dlnaOrgFlagStreamingTransferMode|
 		dlnaOrgFlagBackgroundTransfertMode|
@@ -56,14 +61,10 @@ func defaultStreamingFlags() string {
 func BuildContentFeatures(mediaType string, seek string, transcode bool) (string, error) {
 	var cf strings.Builder
 
-	if mediaType != "" {
-		dlnaProf, profExists := dlnaprofiles[mediaType]
-		switch profExists {
-		case true:
-			cf.WriteString(dlnaProf + ";")
-		default:
-			return "", errors.New("non supported mediaType")
-		}
+	// Media types without a DLNA profile are
+	// still playable, we just don't name one.
+	if dlnaProf, profExists := dlnaprofiles[mediaType]; profExists {
+		cf.WriteString(dlnaProf + ";")
 	}
 
 	// "00" neither time seek range nor range supported
@@ -96,6 +97,61 @@ func BuildContentFeatures(mediaType string, seek string, transcode bool) (string
 	return cf.String(), nil
 }
 
+// MatchProtocolInfo - Pick the entry of the media renderer Sink
+// protocolInfo list that best fits the mediaType. Entries with
+// the DLNA profile we'd use for the mediaType come first, then
+// the ones without any profile, then any other entry for the
+// mediaType. A renderer accepting everything ("http-get:*:*:*")
+// gets a generic protocolInfo for the mediaType.
+func MatchProtocolInfo(mediaType string, sink []string) (string, error) {
+	want := normalizeMediaType(mediaType)
+	profile := strings.TrimPrefix(dlnaprofiles[want], "DLNA.ORG_PN=")
+
+	var exact, wildcard string
+	for _, pi := range sink {
+		pi = strings.TrimSpace(pi)
+		fields := strings.SplitN(pi, ":", 4)
+		if len(fields) != 4 || fields[0] != "http-get" {
+			continue
+		}
+
+		if fields[2] == "*" {
+			wildcard = "http-get:*:" + mediaType + ":*"
+			continue
+		}
+
+		if normalizeMediaType(fields[2]) != want {
+			continue
+		}
+
+		if profile != "" && strings.Contains(fields[3], "DLNA.ORG_PN="+profile) {
+			return pi, nil
+		}
+
+		if exact == "" || fields[3] == "*" {
+			exact = pi
+		}
+	}
+
+	switch {
+	case exact != "":
+		return exact, nil
+	case wildcard != "":
+		return wildcard, nil
+	}
+
+	return "", fmt.Errorf("renderer does not support %s", mediaType)
+}
+
+func normalizeMediaType(mediaType string) string {
+	mediaType = strings.ToLower(mediaType)
+	if alias, ok := mediaTypeAliases[mediaType]; ok {
+		return alias
+	}
+
+	return mediaType
+}
+
 // GetMimeDetailsFromFile - Get media file mime details.
 func GetMimeDetailsFromFile(f string) (string, error) {
 	file, err := os.Open(f)
@@ -0,0 +1,79 @@
+package utils
+
+import (
+	"testing"
+)
+
+func TestMatchProtocolInfo(t *testing.T) {
+	sink := []string{
+		"http-get:*:video/mp4:DLNA.ORG_PN=AVC_MP4_BL_CIF15_AAC_520",
+		"http-get:*:video/mp4:DLNA.ORG_PN=AVC_MP4_MP_SD_AAC_MULT5;DLNA.ORG_OP=01",
+		" http-get:*:video/x-mkv:*",
+		"http-get:*:audio/mpeg:*",
+		"http-get:*:audio/mpeg:DLNA.ORG_PN=MP3",
+		"http-get:*:image/png:DLNA.ORG_PN=PNG_TN",
+		"rtsp-rtp-udp:*:video/x-msvideo:*",
+	}
+
+	tt := []struct {
+		name      string
+		mediaType string
+		sink      []string
+		want      string
+		wantErr   bool
+	}{
+		{
+			`Matching DLNA profile`,
+			"video/mp4",
+			sink,
+			"http-get:*:video/mp4:DLNA.ORG_PN=AVC_MP4_MP_SD_AAC_MULT5;DLNA.ORG_OP=01",
+			false,
+		},
+		{
+			`Media type alias`,
+			"video/x-matroska",
+			sink,
+			"http-get:*:video/x-mkv:*",
+			false,
+		},
+		{
+			`Profile preferred over wildcard`,
+			"audio/mpeg",
+			sink,
+			"http-get:*:audio/mpeg:DLNA.ORG_PN=MP3",
+			false,
+		},
+		{
+			`Other profile of the same media type`,
+			"image/png",
+			sink,
+			"http-get:*:image/png:DLNA.ORG_PN=PNG_TN",
+			false,
+		},
+		{
+			`Not over HTTP`,
+			"video/x-msvideo",
+			sink,
+			"",
+			true,
+		},
+		{
+			`Renderer accepting everything`,
+			"video/webm",
+			[]string{"http-get:*:*:*"},
+			"http-get:*:video/webm:*",
+			false,
+		},
+	}
+
+	for _, tc := range tt {
+		out, err := MatchProtocolInfo(tc.mediaType, tc.sink)
+		if (err != nil) != tc.wantErr {
+			t.Errorf("%s: got error: %v, want error: %t.", tc.name, err, tc.wantErr)
+			continue
+		}
+		if out != tc.want {
+			t.Errorf("%s: got: %s, want: %s.", tc.name, out, tc.want)
+		}
+	}
+}
@@ -33,16 +33,28 @@ func URLtoListenIPandPort(u string) (string, error) {
 	}
 
 	ipToListen := strings.Split(conn.LocalAddr().String(), ":")[0]
-	portToListen, err := checkAndPickPort(ipToListen, 3500)
+	conn.Close()
+
+	res, err := ListenIPandPort(ipToListen)
 	if err != nil {
-		return "", fmt.Errorf("URLtoListenIPandPort port error: %w", err)
+		return "", fmt.Errorf("URLtoListenIPandPort %w", err)
 	}
 
-	res := net.JoinHostPort(ipToListen, portToListen)
-
 	return res, nil
 }
 
+// ListenIPandPort - Pick a free port on the given local IP,
+// e.g. the address of the interface a media renderer was
+// found on.
+func ListenIPandPort(ip string) (string, error) {
+	portToListen, err := checkAndPickPort(ip, 3500)
+	if err != nil {
+		return "", fmt.Errorf("port error: %w", err)
+	}
+
+	return net.JoinHostPort(ip, portToListen), nil
+}
+
 func checkAndPickPort(ip string, port int) (string, error) {
 	var numberOfchecks int
 CHECK:
@@ -0,0 +1,155 @@
+package utils
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MediaDuration - Get the duration of an MP4/MOV media file
+// from the movie header (mvhd) box of its moov box.
+func MediaDuration(f string) (time.Duration, error) {
+	file, err := os.Open(f)
+	if err != nil {
+		return 0, fmt.Errorf("mediaDuration error: %w", err)
+	}
+	defer file.Close()
+
+	stat, err := file.Stat()
+	if err != nil {
+		return 0, fmt.Errorf("mediaDuration stat error: %w", err)
+	}
+
+	moov, moovSize, err := findBox(file, 0, stat.Size(), "moov")
+	if err != nil {
+		return 0, fmt.Errorf("mediaDuration error: %w", err)
+	}
+
+	mvhd, _, err := findBox(file, moov, moov+moovSize, "mvhd")
+	if err != nil {
+		return 0, fmt.Errorf("mediaDuration error: %w", err)
+	}
+
+	// version(1) flags(3), then the creation and modification
+	// times, the timescale and the duration. Version 1 uses
+	// 64bit values for all but the timescale.
+	head := make([]byte, 32)
+	if _, err := file.ReadAt(head, mvhd); err != nil && err != io.EOF {
+		return 0, fmt.Errorf("mediaDuration read error: %w", err)
+	}
+
+	var timescale, duration uint64
+	switch head[0] {
+	case 0:
+		timescale = uint64(binary.BigEndian.Uint32(head[12:16]))
+		duration = uint64(binary.BigEndian.Uint32(head[16:20]))
+	case 1:
+		timescale = uint64(binary.BigEndian.Uint32(head[20:24]))
+		duration = binary.BigEndian.Uint64(head[24:32])
+	default:
+		return 0, errors.New("mediaDuration: unknown mvhd version")
+	}
+
+	if timescale == 0 || duration == 0 {
+		return 0, errors.New("mediaDuration: unknown duration")
+	}
+
+	return time.Duration(float64(duration) / float64(timescale) * float64(time.Second)), nil
+}
+
+// findBox - Find the box of the given type between the offsets
+// from and to, and return the offset and size of its payload.
+func findBox(r io.ReaderAt, from, to int64, boxType string) (int64, int64, error) {
+	head := make([]byte, 16)
+	for off := from; off+8 <= to; {
+		if _, err := r.ReadAt(head[:8], off); err != nil {
+			return 0, 0, fmt.Errorf("findBox read error: %w", err)
+		}
+
+		size := int64(binary.BigEndian.Uint32(head[:4]))
+		headSize := int64(8)
+
+		switch size {
+		case 0:
+			// The box extends to the end of the file.
+			size = to - off
+		case 1:
+			if _, err := r.ReadAt(head[8:16], off+8); err != nil {
+				return 0, 0, fmt.Errorf("findBox read error: %w", err)
+			}
+			size = int64(binary.BigEndian.Uint64(head[8:16]))
+			headSize = 16
+		}
+
+		if size < headSize {
+			return 0, 0, errors.New("findBox: invalid box size")
+		}
+
+		if string(head[4:8]) == boxType {
+			return off + headSize, size - headSize, nil
+		}
+
+		off += size
+	}
+
+	return 0, 0, fmt.Errorf("findBox: no %s box", boxType)
+}
+
+// ParseNPTRange - Parse the "npt=start-[end]" value of the
+// TimeSeekRange.dlna.org header. end is -1 if not set.
+func ParseNPTRange(h string) (time.Duration, time.Duration, error) {
+	h = strings.TrimSpace(h)
+	if !strings.HasPrefix(h, "npt=") {
+		return 0, 0, errors.New("parseNPTRange: invalid range")
+	}
+
+	parts := strings.SplitN(strings.TrimPrefix(h, "npt="), "-", 2)
+	if len(parts) != 2 {
+		return 0, 0, errors.New("parseNPTRange: invalid range")
+	}
+
+	start, err := parseNPTTime(parts[0])
+	if err != nil {
+		return 0, 0, err
+	}
+
+	end := time.Duration(-1)
+	if e := strings.TrimSpace(parts[1]); e != "" {
+		if end, err = parseNPTTime(e); err != nil {
+			return 0, 0, err
+		}
+		if end < start {
+			return 0, 0, errors.New("parseNPTRange: invalid range")
+		}
+	}
+
+	return start, end, nil
+}
+
+// parseNPTTime - Parse an NPT time, either as
+// seconds or as H+:MM:SS, with an optional fraction.
+func parseNPTTime(t string) (time.Duration, error) {
+	t = strings.TrimSpace(t)
+
+	var seconds float64
+	for _, part := range strings.Split(t, ":") {
+		n, err := strconv.ParseFloat(part, 64)
+		if err != nil || n < 0 || math.IsNaN(n) || math.IsInf(n, 0) {
+			return 0, errors.New("parseNPTTime: invalid time format")
+		}
+		seconds = seconds*60 + n
+	}
+
+	return time.Duration(seconds * float64(time.Second)), nil
+}
+
+// FormatNPTTime - Format a duration as an NPT time in seconds.
+func FormatNPTTime(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds(), 'f', 3, 64)
+}
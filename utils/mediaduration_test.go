@@ -0,0 +1,87 @@
+package utils
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func box(boxType string, payload []byte) []byte {
+	b := make([]byte, 8, 8+len(payload))
+	binary.BigEndian.PutUint32(b, uint32(8+len(payload)))
+	copy(b[4:], boxType)
+	return append(b, payload...)
+}
+
+func TestMediaDuration(t *testing.T) {
+	// version 0, timescale 1000, duration 90.5s
+	mvhd := make([]byte, 100)
+	binary.BigEndian.PutUint32(mvhd[12:], 1000)
+	binary.BigEndian.PutUint32(mvhd[16:], 90500)
+
+	data := append(box("ftyp", []byte("isom0000")), box("free", nil)...)
+	data = append(data, box("moov", box("mvhd", mvhd))...)
+
+	f := filepath.Join(t.TempDir(), "test.mp4")
+	if err := os.WriteFile(f, data, 0o600); err != nil {
+		t.Fatalf("write error: %v", err)
+	}
+
+	d, err := MediaDuration(f)
+	if err != nil {
+		t.Fatalf("Failed to call MediaDuration due to %s", err.Error())
+	}
+
+	if want := 90500 * time.Millisecond; d != want {
+		t.Errorf("MediaDuration: got: %s, want: %s.", d, want)
+	}
+
+	if err := os.WriteFile(f, box("ftyp", []byte("isom0000")), 0o600); err != nil {
+		t.Fatalf("write error: %v", err)
+	}
+
+	if _, err := MediaDuration(f); err == nil {
+		t.Errorf("MediaDuration: expected error for a file without a moov box")
+	}
+}
+
+func TestParseNPTRange(t *testing.T) {
+	tt := []struct {
+		name      string
+		input     string
+		wantStart time.Duration
+		wantEnd   time.Duration
+	}{
+		{
+			`Test #1`,
+			`npt=10.5-`,
+			10500 * time.Millisecond,
+			-1,
+		},
+		{
+			`Test #2`,
+			`npt=0:01:00-0:02:00.250`,
+			time.Minute,
+			2*time.Minute + 250*time.Millisecond,
+		},
+	}
+
+	for _, tc := range tt {
+		start, end, err := ParseNPTRange(tc.input)
+		if err != nil {
+			t.Errorf("%s: Failed to call ParseNPTRange due to %s", tc.name, err.Error())
+			continue
+		}
+		if start != tc.wantStart || end != tc.wantEnd {
+			t.Errorf("%s: got: %s-%s, want: %s-%s.", tc.name, start, end, tc.wantStart, tc.wantEnd)
+		}
+	}
+
+	for _, input := range []string{"", "bytes=0-", "npt=20-10", "npt=abc-", "npt=NaN-", "npt=Inf-", "npt=1e309-", "npt=0-NaN", "npt=0-Inf"} {
+		if _, _, err := ParseNPTRange(input); err == nil {
+			t.Errorf("ParseNPTRange: expected error for input %q", input)
+		}
+	}
+}